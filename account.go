@@ -6,13 +6,16 @@ import (
 	"errors"
 	"math/big"
 
+	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 )
 
 var ErrTransactionFailed = errors.New("Transaction Failed")
+var ErrTransactionPending = errors.New("Transaction Pending")
 
 func NewAccount() *Account {
 	k, err := crypto.GenerateKey()
@@ -48,8 +51,11 @@ func (a *Account) MustTransfer(be TestBackend, to common.Address, amount *big.In
 	}
 }
 
+// SignTransaction signs the transaction with the signer of the backend's chain config,
+// so transactions are replay protected with the chain id of the backend
+// and access list and dynamic fee transactions can be signed once Berlin and London are enabled.
 func (a *Account) SignTransaction(be TestBackend, tx *types.Transaction) (*types.Transaction, error) {
-	return types.SignTx(tx, types.HomesteadSigner{}, a.pk)
+	return types.SignTx(tx, types.LatestSigner(be.Blockchain().Config()), a.pk)
 }
 
 func (a *Account) Transfer(be TestBackend, to common.Address, amount *big.Int) error {
@@ -58,14 +64,12 @@ func (a *Account) Transfer(be TestBackend, to common.Address, amount *big.Int) e
 		return err
 	}
 
-	gasPrice, err := be.SuggestGasPrice(context.Background())
+	tx, err := newTransferTx(be, n, to, amount)
 	if err != nil {
 		return err
 	}
 
-	tx := types.NewTransaction(n, to, amount, 41000, gasPrice, nil)
-
-	signed, err := types.SignTx(tx, types.HomesteadSigner{}, a.pk)
+	signed, err := a.SignTransaction(be, tx)
 	if err != nil {
 		return err
 	}
@@ -75,18 +79,65 @@ func (a *Account) Transfer(be TestBackend, to common.Address, amount *big.Int) e
 		return err
 	}
 
-	be.Commit()
+	// transaction has already been mined if the backend is automining
+	_, pending, err := be.TransactionByHash(context.Background(), signed.Hash())
+	if err != nil {
+		return err
+	}
+	if pending {
+		err = be.Commit()
+		if err != nil {
+			return err
+		}
+	}
 
 	rcpt, err := be.TransactionReceipt(context.Background(), signed.Hash())
 	if err != nil {
 		return err
 	}
+	if rcpt == nil {
+		return ErrTransactionPending
+	}
 	if rcpt.Status != types.ReceiptStatusSuccessful {
 		return ErrTransactionFailed
 	}
 	return nil
 }
 
+// newTransferTx creates a dynamic fee transaction when the latest block has a base fee,
+// otherwise a legacy transaction with the suggested gas price.
+func newTransferTx(be TestBackend, nonce uint64, to common.Address, amount *big.Int) (*types.Transaction, error) {
+	ctx := context.Background()
+	head, err := be.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if head.BaseFee == nil {
+		gasPrice, err := be.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewTransaction(nonce, to, amount, 41000, gasPrice, nil), nil
+	}
+
+	tip, err := be.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// same fee cap as bind uses: the base fee can double before the transaction is mined
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   be.Blockchain().Config().ChainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       41000,
+		To:        &to,
+		Value:     amount,
+	}), nil
+}
+
 type TransactionOptionModifier func(*bind.TransactOpts)
 
 func WithGasLimit(gasLimit uint64) func(*bind.TransactOpts) {
@@ -101,20 +152,71 @@ func WithGasPrice(gasPrice *big.Int) func(*bind.TransactOpts) {
 	}
 }
 
+// WithGasFeeCap sets the max fee per gas of dynamic fee transactions.
+func WithGasFeeCap(gasFeeCap *big.Int) func(*bind.TransactOpts) {
+	return func(to *bind.TransactOpts) {
+		to.GasFeeCap = gasFeeCap
+	}
+}
+
+// WithGasTipCap sets the max priority fee per gas of dynamic fee transactions.
+func WithGasTipCap(gasTipCap *big.Int) func(*bind.TransactOpts) {
+	return func(to *bind.TransactOpts) {
+		to.GasTipCap = gasTipCap
+	}
+}
+
 func WithValue(value *big.Int) func(*bind.TransactOpts) {
 	return func(to *bind.TransactOpts) {
 		to.Value = value
 	}
 }
 
-func (a *Account) TransactOpts(modifiers ...TransactionOptionModifier) *bind.TransactOpts {
-	to := bind.NewKeyedTransactor(a.pk)
+// TransactOpts creates TransactOpts signing transactions with the signer of the backend's chain config.
+func (a *Account) TransactOpts(be TestBackend, modifiers ...TransactionOptionModifier) *bind.TransactOpts {
+	signer := types.LatestSigner(be.Blockchain().Config())
+	to := &bind.TransactOpts{
+		From: a.Address(),
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != a.Address() {
+				return nil, bind.ErrNotAuthorized
+			}
+			return types.SignTx(tx, signer, a.pk)
+		},
+		Context: context.Background(),
+	}
 	for _, m := range modifiers {
 		m(to)
 	}
 	return to
 }
 
+// SignMessage signs the message the way personal_sign does:
+// the message is prefixed with "\x19Ethereum Signed Message:\n" and its length before hashing.
+// The signature is in the [R || S || V] format with V being 27 or 28, as expected by ecrecover.
+func (a *Account) SignMessage(message []byte) ([]byte, error) {
+	return a.signHash(accounts.TextHash(message))
+}
+
+// SignTypedData signs the EIP-712 typed data the way eth_signTypedData_v4 does.
+// The signature is in the [R || S || V] format with V being 27 or 28, as expected by ecrecover.
+func (a *Account) SignTypedData(typedData apitypes.TypedData) ([]byte, error) {
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+	return a.signHash(hash)
+}
+
+func (a *Account) signHash(hash []byte) ([]byte, error) {
+	sig, err := crypto.Sign(hash, a.pk)
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig, nil
+}
+
 func (a *Account) Balance(be TestBackend) *big.Int {
 	b, err := be.BalanceAt(context.Background(), a.Address(), nil)
 	if err != nil {
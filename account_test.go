@@ -0,0 +1,144 @@
+package ethertest_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/require"
+	"github.com/tokencard/ethertest"
+)
+
+func TestTransactOpts(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend(ethertest.WithChainID(big.NewInt(5)), ethertest.WithHardfork(ethertest.Istanbul))
+	defer be.Close()
+
+	_, tx, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+	require.Equal(big.NewInt(5), tx.ChainId())
+	require.Equal(uint8(types.LegacyTxType), tx.Type())
+
+	successful, err := ethertest.IsSuccessful(be, tx)
+	require.Nil(err)
+	require.True(successful)
+
+	be = tr.NewTestBackend(ethertest.WithChainID(big.NewInt(5)))
+	defer be.Close()
+
+	_, tx, err = tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+	require.Equal(uint8(types.DynamicFeeTxType), tx.Type())
+
+	other := ethertest.NewAccount()
+	_, err = owner.TransactOpts(be).Signer(other.Address(), tx)
+	require.NotNil(err)
+}
+
+func TestSignMessage(t *testing.T) {
+	var owner = ethertest.NewAccount()
+
+	require := require.New(t)
+	message := []byte("hello")
+	sig, err := owner.SignMessage(message)
+	require.Nil(err)
+	require.Len(sig, 65)
+	require.Contains([]byte{27, 28}, sig[64])
+
+	sig[64] -= 27
+	pub, err := crypto.SigToPub(accounts.TextHash(message), sig)
+	require.Nil(err)
+	require.Equal(owner.Address(), crypto.PubkeyToAddress(*pub))
+}
+
+func TestSignTypedData(t *testing.T) {
+	// example from the EIP-712 specification
+	pk, err := crypto.ToECDSA(crypto.Keccak256([]byte("cow")))
+	require.Nil(t, err)
+	var owner = ethertest.NewAccountFromPrivKey(pk)
+
+	person := []apitypes.Type{
+		{Name: "name", Type: "string"},
+		{Name: "wallet", Type: "address"},
+	}
+	typedData := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"Person": person,
+			"Mail": {
+				{Name: "from", Type: "Person"},
+				{Name: "to", Type: "Person"},
+				{Name: "contents", Type: "string"},
+			},
+		},
+		PrimaryType: "Mail",
+		Domain: apitypes.TypedDataDomain{
+			Name:              "Ether Mail",
+			Version:           "1",
+			ChainId:           math.NewHexOrDecimal256(1),
+			VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+		},
+		Message: apitypes.TypedDataMessage{
+			"from": map[string]interface{}{
+				"name":   "Cow",
+				"wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826",
+			},
+			"to": map[string]interface{}{
+				"name":   "Bob",
+				"wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB",
+			},
+			"contents": "Hello, Bob!",
+		},
+	}
+
+	require := require.New(t)
+	require.Equal(common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"), owner.Address())
+
+	sig, err := owner.SignTypedData(typedData)
+	require.Nil(err)
+	require.Equal("0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b915621c", hexutil.Encode(sig))
+
+	typedData.PrimaryType = "NoSuchType"
+	_, err = owner.SignTypedData(typedData)
+	require.NotNil(err)
+}
+
+func TestSignTransaction(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+
+	require := require.New(t)
+	be := tr.NewTestBackend(ethertest.WithChainID(big.NewInt(42)))
+	defer be.Close()
+
+	tx := types.NewTransaction(0, receiver.Address(), ethertest.EthToWei(1), 21000, ethertest.GweiToWei(2), nil)
+	tx, err := owner.SignTransaction(be, tx)
+	require.Nil(err)
+	require.True(tx.Protected())
+	require.Equal(big.NewInt(42), tx.ChainId())
+	require.Nil(be.SendTransaction(context.Background(), tx))
+	require.Nil(be.Commit())
+	require.Equal(ethertest.EthToWei(1), receiver.Balance(be))
+}
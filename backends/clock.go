@@ -0,0 +1,71 @@
+package backends
+
+import (
+	"fmt"
+	"time"
+)
+
+// SetNextBlockTimestamp sets the timestamp of the pending block.
+// It has to be after the timestamp of the head of the chain, following blocks continue from it.
+func (b *SimulatedBackend) SetNextBlockTimestamp(t time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.setNextBlockTimestamp(t)
+}
+
+func (b *SimulatedBackend) setNextBlockTimestamp(t time.Time) error {
+	offset := b.pendingTimeOffset
+	b.pendingTimeOffset = t.Unix() - int64(b.headBlock().Time()) - b.blockInterval
+	if err := b.updatePending(); err != nil {
+		b.pendingTimeOffset = offset
+		return err
+	}
+	return nil
+}
+
+// WarpTo commits the pending block with the timestamp t,
+// so the head of the chain and calls to contracts see t as the current time.
+func (b *SimulatedBackend) WarpTo(t time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.setNextBlockTimestamp(t); err != nil {
+		return err
+	}
+	return b.commit()
+}
+
+// MineBlocks commits n blocks, the first one includes the pending transactions.
+// Timestamps of the blocks are the block interval apart.
+func (b *SimulatedBackend) MineBlocks(n int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		if err := b.commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetBlockInterval sets the time between two blocks, starting with the pending block.
+// The interval is rounded down to whole seconds and has to be at least one second.
+func (b *SimulatedBackend) SetBlockInterval(interval time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	seconds := int64(interval / time.Second)
+	if seconds < 1 {
+		return fmt.Errorf("block interval %s is shorter than one second", interval)
+	}
+
+	previous := b.blockInterval
+	b.blockInterval = seconds
+	if err := b.updatePending(); err != nil {
+		b.blockInterval = previous
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package backends
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SignImpersonated adds a placeholder signature to the transaction, marking it as sent from the address.
+// The address doesn't need a private key, but SimulatedBackend accepts the transaction
+// only if the address is impersonated with Impersonate.
+func SignImpersonated(tx *types.Transaction, signer types.Signer, address common.Address) (*types.Transaction, error) {
+	sig := make([]byte, 65)
+	copy(sig[32-common.AddressLength:32], address[:])
+	sig[63] = 1
+	return tx.WithSignature(signer, sig)
+}
+
+// impersonatedSender returns the address the transaction was signed for with SignImpersonated.
+// The placeholder signature has the address in R and 1 in S, which is not a valid ECDSA signature
+// of any real key in practice.
+func impersonatedSender(tx *types.Transaction) (common.Address, bool) {
+	_, r, s := tx.RawSignatureValues()
+	if s == nil || r == nil || s.Cmp(common.Big1) != 0 || r.BitLen() > 8*common.AddressLength {
+		return common.Address{}, false
+	}
+	return common.BigToAddress(r), true
+}
+
+// impersonationSigner is a signer that returns the impersonated address as the sender.
+// It is only equal to the impersonation signer of the same address and chain,
+// so the sender it caches in the transaction is not returned to other signers.
+type impersonationSigner struct {
+	types.Signer
+	from common.Address
+}
+
+func (s impersonationSigner) Sender(tx *types.Transaction) (common.Address, error) {
+	return s.from, nil
+}
+
+func (s impersonationSigner) Equal(other types.Signer) bool {
+	o, ok := other.(impersonationSigner)
+	return ok && o.from == s.from && s.Signer.Equal(o.Signer)
+}
+
+// transactionToMessage converts the transaction like core.TransactionToMessage,
+// taking the sender of a transaction signed with SignImpersonated from its placeholder signature.
+// Like on development nodes, an impersonated sender isn't required to be an externally owned account.
+func transactionToMessage(tx *types.Transaction, signer types.Signer, baseFee *big.Int) (*core.Message, error) {
+	from, impersonated := impersonatedSender(tx)
+	if impersonated {
+		signer = impersonationSigner{signer, from}
+	}
+	msg, err := core.TransactionToMessage(tx, signer, baseFee)
+	if err != nil {
+		return nil, err
+	}
+	// skips the nonce check as well, the nonces of the transactions in a block are checked when it's built
+	msg.SkipAccountChecks = impersonated
+	return msg, nil
+}
+
+// Impersonate makes the backend accept transactions signed for the address with SignImpersonated.
+func (b *SimulatedBackend) Impersonate(address common.Address) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.impersonated[address] = true
+}
+
+// StopImpersonating makes the backend reject transactions signed for the address with SignImpersonated.
+// Transactions already in the pool are still mined.
+func (b *SimulatedBackend) StopImpersonating(address common.Address) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.impersonated, address)
+}
+
+// sender returns the sender of the transaction, accepting placeholder signatures of impersonated addresses.
+func (b *SimulatedBackend) sender(tx *types.Transaction) (common.Address, error) {
+	from, ok := impersonatedSender(tx)
+	if !ok {
+		return types.Sender(b.signer(), tx)
+	}
+	if !b.impersonated[from] {
+		return common.Address{}, fmt.Errorf("address %s is not impersonated", from.Hex())
+	}
+	if tx.ChainId().Sign() != 0 && tx.ChainId().Cmp(b.config.ChainID) != 0 {
+		return common.Address{}, fmt.Errorf("invalid chain id %s, want %s", tx.ChainId(), b.config.ChainID)
+	}
+	return from, nil
+}
+
+// TransactionSender returns the sender of the transaction, including transactions sent by impersonated addresses.
+func (b *SimulatedBackend) TransactionSender(tx *types.Transaction) (common.Address, error) {
+	if from, ok := impersonatedSender(tx); ok {
+		return from, nil
+	}
+	return types.Sender(b.signer(), tx)
+}
+
+// fixImpersonatedReceipt sets the contract address of a receipt of a contract creation sent
+// by an impersonated address, which can't be derived from the placeholder signature.
+func fixImpersonatedReceipt(receipt *types.Receipt, tx *types.Transaction) {
+	if tx == nil || tx.To() != nil {
+		return
+	}
+	if from, ok := impersonatedSender(tx); ok {
+		receipt.ContractAddress = crypto.CreateAddress(from, tx.Nonce())
+	}
+}
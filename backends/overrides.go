@@ -0,0 +1,131 @@
+package backends
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// stateOverride is a direct change of the state that is not caused by a transaction.
+// Overrides are not part of the block they are applied to, so blocks with overrides aren't valid chain data:
+// they are kept in the overrides of the backend by block hash, and forking, replaying or re-importing
+// a block relies on them being applied again by the backend.
+type stateOverride func(statedb *state.StateDB)
+
+// SetBalance sets the balance of the account.
+// The change is applied at the start of the pending block and becomes part of the chain with the next Commit.
+func (b *SimulatedBackend) SetBalance(address common.Address, balance *big.Int) error {
+	if balance.Sign() < 0 {
+		return errors.New("balance can't be negative")
+	}
+	balance = new(big.Int).Set(balance)
+	return b.override(func(statedb *state.StateDB) {
+		statedb.SetBalance(address, balance)
+	})
+}
+
+// SetNonce sets the nonce of the account.
+// The change is applied at the start of the pending block and becomes part of the chain with the next Commit.
+func (b *SimulatedBackend) SetNonce(address common.Address, nonce uint64) error {
+	return b.override(func(statedb *state.StateDB) {
+		statedb.SetNonce(address, nonce)
+	})
+}
+
+// SetCode sets the runtime bytecode of the account, e.g. to replace a contract with a mock.
+// The change is applied at the start of the pending block and becomes part of the chain with the next Commit.
+func (b *SimulatedBackend) SetCode(address common.Address, code []byte) error {
+	code = common.CopyBytes(code)
+	return b.override(func(statedb *state.StateDB) {
+		statedb.SetCode(address, code)
+	})
+}
+
+// SetStorageAt sets the value of the storage slot of the account.
+// The change is applied at the start of the pending block and becomes part of the chain with the next Commit.
+func (b *SimulatedBackend) SetStorageAt(address common.Address, key, value common.Hash) error {
+	return b.override(func(statedb *state.StateDB) {
+		statedb.SetState(address, key, value)
+	})
+}
+
+// override adds the state override to the pending block.
+func (b *SimulatedBackend) override(o stateOverride) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pendingOverrides = append(b.pendingOverrides, o)
+	if err := b.updatePending(); err != nil {
+		b.pendingOverrides = b.pendingOverrides[:len(b.pendingOverrides)-1]
+		return err
+	}
+	return nil
+}
+
+// pendingParentState returns the state of the head of the chain with the pending state overrides applied,
+// which is the state the transactions of the pending block start from.
+func (b *SimulatedBackend) pendingParentState() (*state.StateDB, error) {
+	statedb, err := b.blockchain.State()
+	if err != nil {
+		return nil, err
+	}
+	number := new(big.Int).Add(b.blockchain.CurrentBlock().Number, common.Big1)
+	b.applyOverrides(statedb, number, b.pendingOverrides)
+	return statedb, nil
+}
+
+// applyOverrides applies the state overrides of the block with the given number.
+// Overrides are finalised like a transaction, so the values are the original values
+// for the gas accounting of the transactions that follow.
+func (b *SimulatedBackend) applyOverrides(statedb *state.StateDB, number *big.Int, overrides []stateOverride) {
+	if len(overrides) == 0 {
+		return
+	}
+	for _, o := range overrides {
+		o(statedb)
+	}
+	statedb.Finalise(b.config.IsEIP158(number))
+}
+
+// overridingProcessor processes blocks like core.StateProcessor, applying the state overrides
+// of a block before its transactions, so blocks with state overrides are accepted when they
+// are inserted into the chain of this backend or a fork of it, but not by any other processor. Transactions are applied with processTransaction,
+// so senders of impersonated transactions are not recovered from their placeholder signatures.
+type overridingProcessor struct {
+	backend *SimulatedBackend
+}
+
+func (p overridingProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	b := p.backend
+	b.applyOverrides(statedb, block.Number(), b.overrides[block.Hash()])
+
+	var (
+		receipts types.Receipts
+		logs     []*types.Log
+		usedGas  uint64
+		header   = block.Header()
+		gasPool  = new(core.GasPool).AddGas(block.GasLimit())
+		vmenv    = vm.NewEVM(core.NewEVMBlockContext(header, b.blockchain, nil), vm.TxContext{}, statedb, b.config, cfg)
+		signer   = types.MakeSigner(b.config, header.Number, header.Time)
+	)
+	if beaconRoot := block.BeaconRoot(); beaconRoot != nil {
+		core.ProcessBeaconBlockRoot(*beaconRoot, vmenv, statedb)
+	}
+	for i, tx := range block.Transactions() {
+		statedb.SetTxContext(tx.Hash(), i)
+		receipt, err := processTransaction(b.config, vmenv, signer, gasPool, statedb, header, block.Hash(), tx, &usedGas)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+		}
+		receipts = append(receipts, receipt)
+		logs = append(logs, receipt.Logs...)
+	}
+	b.engine.Finalize(b.blockchain, header, statedb, block.Transactions(), block.Uncles(), block.Withdrawals())
+	return receipts, logs, usedGas, nil
+}
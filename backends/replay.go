@@ -0,0 +1,91 @@
+package backends
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// replay is the state of a block right before one of its transactions.
+type replay struct {
+	block   *types.Block
+	index   int
+	context vm.BlockContext
+	signer  types.Signer
+	statedb *state.StateDB
+}
+
+// replayTransaction re-executes the mined transaction on top of the state
+// its block had before the transaction, using the vm config for it.
+func (b *SimulatedBackend) replayTransaction(txHash common.Hash, vmc vm.Config) (*core.ExecutionResult, error) {
+	r, err := b.stateBeforeTransaction(txHash)
+	if err != nil {
+		return nil, err
+	}
+	return r.apply(b.config, r.index, vmc)
+}
+
+// stateBeforeTransaction re-executes the transactions of the block preceding the mined transaction.
+func (b *SimulatedBackend) stateBeforeTransaction(txHash common.Hash) (*replay, error) {
+	tx, blockHash, blockNumber, index := rawdb.ReadTransaction(b.database, txHash)
+	if tx == nil {
+		return nil, errTransactionDoesNotExist
+	}
+	block := b.blockchain.GetBlock(blockHash, blockNumber)
+	if block == nil {
+		return nil, errBlockDoesNotExist
+	}
+	parent := b.blockchain.GetBlock(block.ParentHash(), blockNumber-1)
+	if parent == nil {
+		return nil, errBlockDoesNotExist
+	}
+	statedb, err := b.blockchain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+
+	// the overrides aren't part of the block, without them the replay would not match the committed state
+	b.applyOverrides(statedb, block.Number(), b.overrides[block.Hash()])
+	r := &replay{
+		block:   block,
+		index:   int(index),
+		context: core.NewEVMBlockContext(block.Header(), b.blockchain, nil),
+		signer:  types.MakeSigner(b.config, block.Number(), block.Time()),
+		statedb: statedb,
+	}
+	if beaconRoot := block.BeaconRoot(); beaconRoot != nil {
+		vmenv := vm.NewEVM(r.context, vm.TxContext{}, statedb, b.config, vm.Config{})
+		core.ProcessBeaconBlockRoot(*beaconRoot, vmenv, statedb)
+	}
+
+	for i := 0; i < r.index; i++ {
+		if _, err := r.apply(b.config, i, vm.Config{}); err != nil {
+			return nil, err
+		}
+		statedb.Finalise(b.config.IsEIP158(block.Number()))
+	}
+	return r, nil
+}
+
+// apply executes the transaction of the block with the index on the replayed state.
+func (r *replay) apply(config *params.ChainConfig, index int, vmc vm.Config) (*core.ExecutionResult, error) {
+	tx := r.block.Transactions()[index]
+	msg, err := transactionToMessage(tx, r.signer, r.block.BaseFee())
+	if err != nil {
+		return nil, err
+	}
+
+	r.statedb.SetTxContext(tx.Hash(), index)
+	vmenv := vm.NewEVM(r.context, core.NewEVMTxContext(msg), r.statedb, config, vmc)
+	result, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas()))
+	if err != nil {
+		return nil, fmt.Errorf("could not replay transaction %s: %v", tx.Hash().Hex(), err)
+	}
+	return result, nil
+}
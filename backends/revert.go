@@ -0,0 +1,78 @@
+package backends
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// revertError is an API error that encompasses an EVM revert with JSON error
+// code and a binary data blob.
+type revertError struct {
+	error
+	reason string // revert reason hex encoded
+}
+
+// newRevertError creates a revertError with the decoded reason in the message.
+// Reverts with custom errors have the hex encoded data in the message,
+// so it is not lost when the error is only formatted, e.g. by bindings failing to estimate gas.
+func newRevertError(result *core.ExecutionResult) *revertError {
+	revert := result.Revert()
+	err := errors.New("execution reverted")
+	if reason, errUnpack := abi.UnpackRevert(revert); errUnpack == nil {
+		err = fmt.Errorf("execution reverted: %v", reason)
+	} else if len(revert) > 0 {
+		err = fmt.Errorf("execution reverted: custom error %s", hexutil.Encode(revert))
+	}
+	return &revertError{
+		error:  err,
+		reason: hexutil.Encode(revert),
+	}
+}
+
+// ErrorCode returns the JSON error code for a revert.
+// See: https://github.com/ethereum/wiki/wiki/JSON-RPC-Error-Codes-Improvement-Proposal
+func (e *revertError) ErrorCode() int {
+	return 3
+}
+
+// ErrorData returns the hex encoded revert reason.
+func (e *revertError) ErrorData() interface{} {
+	return e.reason
+}
+
+// executionError returns the error of a failed execution, a revertError if it reverted.
+func executionError(result *core.ExecutionResult) error {
+	if len(result.Revert()) > 0 || errors.Is(result.Err, vm.ErrExecutionReverted) {
+		return newRevertError(result)
+	}
+	return result.Err
+}
+
+// TransactionError re-executes the mined transaction and returns the error of its execution,
+// e.g. a revert error with the revert reason, or nil if the transaction was successful.
+// The re-execution isn't traced by the tracer of the backend.
+func (b *SimulatedBackend) TransactionError(ctx context.Context, txHash common.Hash) error {
+	return b.TraceTransactionError(ctx, txHash, nil)
+}
+
+// TraceTransactionError re-executes the mined transaction like TransactionError, traced by the tracer.
+func (b *SimulatedBackend) TraceTransactionError(ctx context.Context, txHash common.Hash, tracer vm.EVMLogger) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result, err := b.replayTransaction(txHash, vm.Config{Tracer: tracer})
+	if err != nil {
+		return err
+	}
+	if result.Failed() {
+		return executionError(result)
+	}
+	return nil
+}
@@ -17,6 +17,7 @@
 package backends
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -28,13 +29,19 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip1559"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/filters"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
@@ -46,12 +53,42 @@ import (
 var _ bind.ContractBackend = (*SimulatedBackend)(nil)
 
 var (
-	errBlockNumberUnsupported  = errors.New("simulatedBackend cannot access blocks other than the latest block")
 	errBlockDoesNotExist       = errors.New("block does not exist in blockchain")
 	errTransactionDoesNotExist = errors.New("transaction does not exist")
 	errGasEstimationFailed     = errors.New("gas required exceeds allowance or always failing transaction")
+	errSnapshotDoesNotExist    = errors.New("snapshot does not exist")
 )
 
+// Errors returned by SendTransaction for transactions a transaction pool of a node would reject.
+var (
+	ErrInvalidSender      = txpool.ErrInvalidSender
+	ErrNonceTooLow        = core.ErrNonceTooLow
+	ErrNonceTooHigh       = core.ErrNonceTooHigh
+	ErrInsufficientFunds  = core.ErrInsufficientFunds
+	ErrIntrinsicGas       = core.ErrIntrinsicGas
+	ErrGasLimitExceeded   = txpool.ErrGasLimit
+	ErrNegativeValue      = txpool.ErrNegativeValue
+	ErrAlreadyKnown       = txpool.ErrAlreadyKnown
+	ErrReplaceUnderpriced = txpool.ErrReplaceUnderpriced
+	ErrTxTypeNotSupported = core.ErrTxTypeNotSupported
+	ErrTipAboveFeeCap     = core.ErrTipAboveFeeCap
+	ErrFeeCapTooLow       = core.ErrFeeCapTooLow
+	ErrSenderNoEOA        = core.ErrSenderNoEOA
+)
+
+const (
+	// priceBump is the minimum gas price increase (in percent) needed to replace a transaction.
+	priceBump = 10
+	// maxNonceGap is the maximum number of nonces a queued transaction can be ahead of the sender's nonce.
+	maxNonceGap = 64
+	// defaultBlockInterval is the number of seconds between two simulated blocks, unless set with SetBlockInterval.
+	defaultBlockInterval = 10
+)
+
+// suggestedGasTipCap is the priority fee suggested by SuggestGasTipCap.
+// The simulated chain has no competing miners, so any tip is enough.
+var suggestedGasTipCap = big.NewInt(1)
+
 // SimulatedBackend implements bind.ContractBackend, simulating a blockchain in
 // the background. Its main purpose is to allow easily testing contract bindings.
 // Simulated backend implements the following interfaces:
@@ -61,44 +98,143 @@ type SimulatedBackend struct {
 	database   ethdb.Database   // In memory database to store our testing data
 	blockchain *core.BlockChain // Ethereum blockchain to handle the consensus
 
-	mu           sync.Mutex
-	pendingBlock *types.Block   // Currently pending block that will be imported on request
-	pendingState *state.StateDB // Currently pending state that will be the active on on request
+	mu              sync.Mutex
+	pendingBlock    *types.Block   // Currently pending block that will be imported on request
+	pendingState    *state.StateDB // Currently pending state that will be the active on on request
+	pendingReceipts types.Receipts // Receipts of the transactions in the pending block
+
+	pool              map[common.Address]map[uint64]*types.Transaction // Transactions that are not mined yet, by sender and nonce
+	impersonated      map[common.Address]bool                          // Addresses that can send transactions without a private key
+	pendingTimeOffset int64                                            // Time shift of the pending block in seconds
+	blockInterval     int64                                            // Seconds between two blocks
+	pendingOverrides  []stateOverride                                  // State changes applied at the start of the pending block
+	overrides         map[common.Hash][]stateOverride                  // State changes applied at the start of committed blocks, not part of the blocks themselves
+
+	events       *filters.EventSystem  // Event system for filtering log events live
+	filterSystem *filters.FilterSystem // Filter system for filtering database logs
 
-	events *filters.EventSystem // Event system for filtering log events live
+	snapshots      []chainSnapshot // Blocks the canonical chain can be reverted to
+	nextSnapshotID int
 
+	engine consensus.Engine
 	config *params.ChainConfig
 	vmc    vm.Config
 }
 
+// chainSnapshot identifies a block of the canonical chain taken by Snapshot.
+type chainSnapshot struct {
+	id     int
+	number uint64
+	hash   common.Hash
+}
+
 // NewSimulatedBackendWithDatabase creates a new binding backend based on the given database
 // and uses a simulated blockchain for testing purposes.
-func NewSimulatedBackendWithDatabase(database ethdb.Database, alloc core.GenesisAlloc, gasLimit uint64, vmc vm.Config, t time.Time) *SimulatedBackend {
-	genesis := core.Genesis{
-		Config:    params.AllEthashProtocolChanges,
+// If config is nil, the chain starts with London rules and chain id 1337.
+// Chains with a terminal total difficulty are proof-of-stake from the genesis block.
+func NewSimulatedBackendWithDatabase(database ethdb.Database, alloc core.GenesisAlloc, gasLimit uint64, config *params.ChainConfig, vmc vm.Config, t time.Time) *SimulatedBackend {
+	if config == nil {
+		config = params.AllEthashProtocolChanges
+	}
+	genesis := &core.Genesis{
+		Config:    config,
 		GasLimit:  gasLimit,
 		Alloc:     alloc,
 		Timestamp: uint64(t.Unix()),
 	}
-	genesis.MustCommit(database)
-	blockchain, _ := core.NewBlockChain(database, nil, genesis.Config, ethash.NewFaker(), vmc, nil)
+	if config.TerminalTotalDifficulty != nil {
+		genesis.Difficulty = big.NewInt(0)
+	}
+	return newSimulatedBackend(database, genesis, vmc)
+}
 
-	backend := &SimulatedBackend{
+// newSimulatedBackend creates a new binding backend using the blockchain stored in the database.
+// If genesis is nil, the database must already contain a chain.
+func newSimulatedBackend(database ethdb.Database, genesis *core.Genesis, vmc vm.Config) *SimulatedBackend {
+	// Disable trie garbage collection, so the chain can be rewound to any block.
+	cacheConfig := &core.CacheConfig{
+		TrieCleanLimit:    256,
+		TrieDirtyLimit:    256,
+		TrieTimeLimit:     5 * time.Minute,
+		TrieDirtyDisabled: true,
+		StateScheme:       rawdb.HashScheme,
+	}
+	// Blocks are generated by the backend itself, so they don't need to be verified.
+	// This also lets the simulated clock run ahead of the wall clock.
+	engine := beacon.New(ethash.NewFullFaker())
+	blockchain, err := core.NewBlockChain(database, cacheConfig, genesis, nil, engine, vmc, nil, nil)
+	if err != nil {
+		panic(err)
+	}
 
-		database:   database,
-		blockchain: blockchain,
-		config:     genesis.Config,
-		events:     filters.NewEventSystem(&filterBackend{database, blockchain}, false),
-		vmc:        vmc,
+	backend := &SimulatedBackend{
+		database:      database,
+		blockchain:    blockchain,
+		engine:        engine,
+		config:        blockchain.Config(),
+		impersonated:  map[common.Address]bool{},
+		overrides:     map[common.Hash][]stateOverride{},
+		blockInterval: defaultBlockInterval,
+		vmc:           vmc,
 	}
+	blockchain.SetBlockValidatorAndProcessorForTesting(blockchain.Validator(), overridingProcessor{backend})
+	backend.filterSystem = filters.NewFilterSystem(&filterBackend{database, blockchain, backend}, filters.Config{})
+	backend.events = filters.NewEventSystem(backend.filterSystem, false)
 	backend.rollback()
 	return backend
 }
 
 // NewSimulatedBackend creates a new binding backend using a simulated blockchain
 // for testing purposes.
-func NewSimulatedBackend(alloc core.GenesisAlloc, gasLimit uint64, vmc vm.Config, t time.Time) *SimulatedBackend {
-	return NewSimulatedBackendWithDatabase(rawdb.NewMemoryDatabase(), alloc, gasLimit, vmc, t)
+func NewSimulatedBackend(alloc core.GenesisAlloc, gasLimit uint64, config *params.ChainConfig, vmc vm.Config, t time.Time) *SimulatedBackend {
+	return NewSimulatedBackendWithDatabase(rawdb.NewMemoryDatabase(), alloc, gasLimit, config, vmc, t)
+}
+
+// Fork creates a new independent backend starting from the current head of the chain.
+// The in-memory database is copied, so transactions sent to either of the backends
+// will not affect the other one. Pending transactions are not copied.
+func (b *SimulatedBackend) Fork() (*SimulatedBackend, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	database := rawdb.NewMemoryDatabase()
+	it := b.database.NewIterator(nil, nil)
+	defer it.Release()
+
+	batch := database.NewBatch()
+	for it.Next() {
+		if err := batch.Put(it.Key(), it.Value()); err != nil {
+			return nil, err
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return nil, err
+			}
+			batch.Reset()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+
+	fork := newSimulatedBackend(database, nil, b.vmc)
+	if fork.blockchain.CurrentBlock().Hash() != b.blockchain.CurrentBlock().Hash() {
+		fork.Close()
+		return nil, fmt.Errorf("could not fork the chain at block %d", b.blockchain.CurrentBlock().Number)
+	}
+	fork.snapshots = append([]chainSnapshot{}, b.snapshots...)
+	for address := range b.impersonated {
+		fork.impersonated[address] = true
+	}
+	for hash, overrides := range b.overrides {
+		fork.overrides[hash] = overrides
+	}
+	fork.nextSnapshotID = b.nextSnapshotID
+	fork.blockInterval = b.blockInterval
+	return fork, nil
 }
 
 // Close terminates the underlying blockchain's update loop.
@@ -107,19 +243,46 @@ func (b *SimulatedBackend) Close() error {
 	return nil
 }
 
-// Commit imports all the pending transactions as a single block and starts a
-// fresh new state.
-func (b *SimulatedBackend) Commit() {
+// Commit imports the pending block and starts a fresh new state.
+// Transactions that didn't fit into the block or are waiting for a nonce gap to be filled
+// stay in the pool and will be included in the following blocks.
+func (b *SimulatedBackend) Commit() error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	return b.commit()
+}
+
+func (b *SimulatedBackend) commit() error {
+	if len(b.pendingOverrides) > 0 {
+		b.overrides[b.pendingBlock.Hash()] = b.pendingOverrides
+	}
 	if _, err := b.blockchain.InsertChain([]*types.Block{b.pendingBlock}); err != nil {
-		panic(err) // This cannot happen unless the simulator is wrong, fail in that case
+		return fmt.Errorf("could not insert block %d: %v", b.pendingBlock.NumberU64(), err)
 	}
-	b.rollback()
+	b.pendingOverrides = nil
+
+	statedb, err := b.blockchain.State()
+	if err != nil {
+		return err
+	}
+	for sender, txs := range b.pool {
+		nonce := statedb.GetNonce(sender)
+		for n := range txs {
+			if n < nonce {
+				delete(txs, n)
+			}
+		}
+		if len(txs) == 0 {
+			delete(b.pool, sender)
+		}
+	}
+
+	b.pendingTimeOffset = 0
+	return b.updatePending()
 }
 
-// Rollback aborts all pending transactions, reverting to the last committed state.
+// Rollback aborts all pending and queued transactions and state overrides, reverting to the last committed state.
 func (b *SimulatedBackend) Rollback() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -127,24 +290,291 @@ func (b *SimulatedBackend) Rollback() {
 	b.rollback()
 }
 
+// Snapshot records the current head of the canonical chain and returns an id
+// that can be used to revert the chain back to it with RevertTo.
+// Pending transactions are not part of the snapshot.
+func (b *SimulatedBackend) Snapshot() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	head := b.blockchain.CurrentBlock()
+	id := b.nextSnapshotID
+	b.nextSnapshotID++
+	b.snapshots = append(b.snapshots, chainSnapshot{
+		id:     id,
+		number: head.Number.Uint64(),
+		hash:   head.Hash(),
+	})
+	return id
+}
+
+// RevertTo rewinds the canonical chain to the block recorded by the snapshot with the given id,
+// discarding all blocks, receipts and logs after it, together with all pending transactions.
+// The snapshot can be reverted to again, snapshots taken after it are invalidated.
+func (b *SimulatedBackend) RevertTo(id int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	idx := -1
+	for i, s := range b.snapshots {
+		if s.id == id {
+			idx = i
+		}
+	}
+	if idx == -1 {
+		return errSnapshotDoesNotExist
+	}
+	s := b.snapshots[idx]
+
+	// the overrides of the discarded blocks must not be applied to other blocks mined at their height
+	for n := b.blockchain.CurrentBlock().Number.Uint64(); n > s.number; n-- {
+		delete(b.overrides, b.blockchain.GetCanonicalHash(n))
+	}
+	if err := b.blockchain.SetHead(s.number); err != nil {
+		return err
+	}
+	if b.blockchain.CurrentBlock().Hash() != s.hash {
+		return fmt.Errorf("could not revert to block %d", s.number)
+	}
+
+	b.snapshots = b.snapshots[:idx+1]
+	b.rollback()
+	return nil
+}
+
 func (b *SimulatedBackend) rollback() {
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.database, 1, func(int, *core.BlockGen) {})
-	statedb, _ := b.blockchain.State()
+	b.pool = map[common.Address]map[uint64]*types.Transaction{}
+	b.pendingTimeOffset = 0
+	b.pendingOverrides = nil
+	// pending block without transactions can always be generated
+	_ = b.updatePending()
+}
+
+// updatePending regenerates the pending block from the state overrides and the transactions in the pool.
+// Executable transactions are ordered by gas price (respecting nonces of each sender)
+// and added to the block while there is enough gas left.
+func (b *SimulatedBackend) updatePending() error {
+	parent := b.headBlock()
+	header, err := b.pendingHeader(parent)
+	if err != nil {
+		return err
+	}
+
+	statedb, err := b.pendingParentState()
+	if err != nil {
+		return err
+	}
+	if header.ParentBeaconRoot != nil {
+		context := core.NewEVMBlockContext(header, b.blockchain, nil)
+		vmenv := vm.NewEVM(context, vm.TxContext{}, statedb, b.config, vm.Config{})
+		core.ProcessBeaconBlockRoot(*header.ParentBeaconRoot, vmenv, statedb)
+	}
+
+	executable := map[common.Address]types.Transactions{}
+	for sender, txs := range b.pool {
+		for n := statedb.GetNonce(sender); txs[n] != nil; n++ {
+			executable[sender] = append(executable[sender], txs[n])
+		}
+	}
+
+	var (
+		txs      types.Transactions
+		receipts types.Receipts
+		gasPool  = new(core.GasPool).AddGas(header.GasLimit)
+	)
+	for sender := nextSender(executable, header.BaseFee); sender != nil; sender = nextSender(executable, header.BaseFee) {
+		tx := executable[*sender][0]
+		receipt, err := b.applyTransaction(header, statedb, gasPool, tx, len(txs))
+		if err != nil {
+			// skip remaining transactions of the sender, they will be retried in the next block
+			delete(executable, *sender)
+			continue
+		}
+		txs = append(txs, tx)
+		receipts = append(receipts, receipt)
+		executable[*sender] = executable[*sender][1:]
+	}
+
+	block, err := b.engine.FinalizeAndAssemble(b.blockchain, header, statedb, txs, nil, receipts, nil)
+	if err != nil {
+		return fmt.Errorf("could not generate block: %v", err)
+	}
+	var blobGasPrice *big.Int
+	if block.ExcessBlobGas() != nil {
+		blobGasPrice = eip4844.CalcBlobFee(*block.ExcessBlobGas())
+	}
+	if err := receipts.DeriveFields(b.config, block.Hash(), block.NumberU64(), block.Time(), block.BaseFee(), blobGasPrice, txs); err != nil {
+		return err
+	}
+	for i, receipt := range receipts {
+		fixImpersonatedReceipt(receipt, txs[i])
+	}
+
+	b.pendingBlock = block
+	b.pendingReceipts = receipts
+	b.pendingState = statedb
+	return nil
+}
+
+// pendingHeader creates the header of the block following the parent, before any transactions are applied.
+func (b *SimulatedBackend) pendingHeader(parent *types.Block) (*types.Header, error) {
+	t := int64(parent.Time()) + b.blockInterval + b.pendingTimeOffset
+	if t <= int64(parent.Time()) {
+		return nil, fmt.Errorf("could not generate block: block time %d is not after parent block time %d", t, parent.Time())
+	}
+
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Coinbase:   parent.Coinbase(),
+		GasLimit:   parent.GasLimit(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		Time:       uint64(t),
+	}
+	if b.config.TerminalTotalDifficulty != nil {
+		header.Difficulty = new(big.Int)
+	} else {
+		header.Difficulty = b.engine.CalcDifficulty(b.blockchain, header.Time, parent.Header())
+	}
+
+	if b.config.IsLondon(header.Number) {
+		header.BaseFee = eip1559.CalcBaseFee(b.config, parent.Header())
+		if !b.config.IsLondon(parent.Number()) {
+			parentGasLimit := parent.GasLimit() * b.config.ElasticityMultiplier()
+			header.GasLimit = core.CalcGasLimit(parentGasLimit, parentGasLimit)
+		}
+	}
+	if b.config.IsCancun(header.Number, header.Time) {
+		var excessBlobGas, blobGasUsed uint64
+		if parent.ExcessBlobGas() != nil {
+			excessBlobGas, blobGasUsed = *parent.ExcessBlobGas(), *parent.BlobGasUsed()
+		}
+		excessBlobGas = eip4844.CalcExcessBlobGas(excessBlobGas, blobGasUsed)
+		header.ExcessBlobGas = &excessBlobGas
+		header.BlobGasUsed = new(uint64)
+		header.ParentBeaconRoot = new(common.Hash)
+	}
+	return header, nil
+}
+
+// applyTransaction applies the transaction to the pending block.
+// If the transaction can't be applied, the state and the gas used by the block are left untouched.
+func (b *SimulatedBackend) applyTransaction(header *types.Header, statedb *state.StateDB, gasPool *core.GasPool, tx *types.Transaction, index int) (*types.Receipt, error) {
+	snapshot, gas, gasUsed := statedb.Snapshot(), gasPool.Gas(), header.GasUsed
+
+	statedb.SetTxContext(tx.Hash(), index)
+	vmenv := vm.NewEVM(core.NewEVMBlockContext(header, b.blockchain, &header.Coinbase), vm.TxContext{}, statedb, b.config, vm.Config{})
+	signer := types.MakeSigner(b.config, header.Number, header.Time)
+	receipt, err := processTransaction(b.config, vmenv, signer, gasPool, statedb, header, header.Hash(), tx, &header.GasUsed)
+	if err != nil {
+		statedb.RevertToSnapshot(snapshot)
+		gasPool.SetGas(gas)
+		header.GasUsed = gasUsed
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// processTransaction applies the transaction to the state like core.ApplyTransaction,
+// converting it into a message with transactionToMessage, so impersonated transactions can be applied.
+func processTransaction(config *params.ChainConfig, vmenv *vm.EVM, signer types.Signer, gasPool *core.GasPool, statedb *state.StateDB, header *types.Header, blockHash common.Hash, tx *types.Transaction, usedGas *uint64) (*types.Receipt, error) {
+	msg, err := transactionToMessage(tx, signer, header.BaseFee)
+	if err != nil {
+		return nil, err
+	}
+	vmenv.Reset(core.NewEVMTxContext(msg), statedb)
+	result, err := core.ApplyMessage(vmenv, msg, gasPool)
+	if err != nil {
+		return nil, err
+	}
+
+	var root []byte
+	if config.IsByzantium(header.Number) {
+		statedb.Finalise(true)
+	} else {
+		root = statedb.IntermediateRoot(config.IsEIP158(header.Number)).Bytes()
+	}
+	*usedGas += result.UsedGas
+
+	receipt := &types.Receipt{Type: tx.Type(), PostState: root, CumulativeGasUsed: *usedGas}
+	if result.Failed() {
+		receipt.Status = types.ReceiptStatusFailed
+	} else {
+		receipt.Status = types.ReceiptStatusSuccessful
+	}
+	receipt.TxHash = tx.Hash()
+	receipt.GasUsed = result.UsedGas
+	if msg.To == nil {
+		receipt.ContractAddress = crypto.CreateAddress(msg.From, tx.Nonce())
+	}
+	receipt.Logs = statedb.GetLogs(tx.Hash(), header.Number.Uint64(), blockHash)
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	receipt.BlockHash = blockHash
+	receipt.BlockNumber = header.Number
+	receipt.TransactionIndex = uint(statedb.TxIndex())
+	return receipt, nil
+}
+
+// nextSender returns the sender whose next executable transaction pays the highest tip
+// to the miner at the given base fee, or nil if there are no transactions left.
+// Transactions with a fee cap below the base fee can't be included and are skipped.
+func nextSender(executable map[common.Address]types.Transactions, baseFee *big.Int) *common.Address {
+	var (
+		best    *common.Address
+		bestTip *big.Int
+	)
+	for sender, txs := range executable {
+		if len(txs) == 0 {
+			continue
+		}
+		tip, err := txs[0].EffectiveGasTip(baseFee)
+		if err != nil {
+			continue
+		}
+		if best != nil {
+			// ties are broken by the sender address to keep the block deterministic
+			if c := tip.Cmp(bestTip); c < 0 || (c == 0 && bytes.Compare(sender[:], best[:]) > 0) {
+				continue
+			}
+		}
+		sender := sender
+		best, bestTip = &sender, tip
+	}
+	return best
+}
 
-	b.pendingBlock = blocks[0]
-	b.pendingState, _ = state.New(b.pendingBlock.Root(), statedb.Database())
+func (b *SimulatedBackend) signer() types.Signer {
+	return types.LatestSigner(b.config)
+}
+
+// blockByNumber retrieves a block of the canonical chain by a given blocknumber.
+// If number is nil, the latest block is returned.
+func (b *SimulatedBackend) blockByNumber(blockNumber *big.Int) (*types.Block, error) {
+	if blockNumber == nil || blockNumber.Cmp(b.blockchain.CurrentBlock().Number) == 0 {
+		return b.headBlock(), nil
+	}
+	if blockNumber.Sign() < 0 || !blockNumber.IsUint64() {
+		return nil, errBlockDoesNotExist
+	}
+	block := b.blockchain.GetBlockByNumber(blockNumber.Uint64())
+	if block == nil {
+		return nil, errBlockDoesNotExist
+	}
+	return block, nil
+}
+
+// headBlock returns the current head of the canonical chain.
+func (b *SimulatedBackend) headBlock() *types.Block {
+	head := b.blockchain.CurrentBlock()
+	return b.blockchain.GetBlock(head.Hash(), head.Number.Uint64())
 }
 
 // stateByBlockNumber retrieves a state by a given blocknumber.
 func (b *SimulatedBackend) stateByBlockNumber(ctx context.Context, blockNumber *big.Int) (*state.StateDB, error) {
-	if blockNumber == nil || blockNumber.Cmp(b.blockchain.CurrentBlock().Number()) == 0 {
-		return b.blockchain.State()
-	}
-	block, err := b.BlockByNumber(ctx, blockNumber)
+	block, err := b.blockByNumber(blockNumber)
 	if err != nil {
 		return nil, err
 	}
-	return b.blockchain.StateAt(block.Hash())
+	return b.blockchain.StateAt(block.Root())
 }
 
 // CodeAt returns the code associated with a certain account in the blockchain.
@@ -206,6 +636,10 @@ func (b *SimulatedBackend) TransactionReceipt(ctx context.Context, txHash common
 	defer b.mu.Unlock()
 
 	receipt, _, _, _ := rawdb.ReadReceipt(b.database, txHash, b.config)
+	if receipt != nil {
+		tx, _, _, _ := rawdb.ReadTransaction(b.database, txHash)
+		fixImpersonatedReceipt(receipt, tx)
+	}
 	return receipt, nil
 }
 
@@ -217,11 +651,14 @@ func (b *SimulatedBackend) TransactionByHash(ctx context.Context, txHash common.
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	tx := b.pendingBlock.Transaction(txHash)
-	if tx != nil {
-		return tx, true, nil
+	for _, txs := range b.pool {
+		for _, tx := range txs {
+			if tx.Hash() == txHash {
+				return tx, true, nil
+			}
+		}
 	}
-	tx, _, _, _ = rawdb.ReadTransaction(b.database, txHash)
+	tx, _, _, _ := rawdb.ReadTransaction(b.database, txHash)
 	if tx != nil {
 		return tx, false, nil
 	}
@@ -252,7 +689,7 @@ func (b *SimulatedBackend) BlockByNumber(ctx context.Context, number *big.Int) (
 	defer b.mu.Unlock()
 
 	if number == nil || number.Cmp(b.pendingBlock.Number()) == 0 {
-		return b.blockchain.CurrentBlock(), nil
+		return b.headBlock(), nil
 	}
 
 	block := b.blockchain.GetBlockByNumber(uint64(number.Int64()))
@@ -345,20 +782,28 @@ func (b *SimulatedBackend) PendingCodeAt(ctx context.Context, contract common.Ad
 	return b.pendingState.GetCode(contract), nil
 }
 
-// CallContract executes a contract call.
+// CallContract executes a contract call on the state of the given block.
+// If blockNumber is nil, the latest block is used.
 func (b *SimulatedBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if blockNumber != nil && blockNumber.Cmp(b.blockchain.CurrentBlock().Number()) != 0 {
-		return nil, errBlockNumberUnsupported
+	block, err := b.blockByNumber(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	state, err := b.blockchain.StateAt(block.Root())
+	if err != nil {
+		return nil, err
 	}
-	state, err := b.blockchain.State()
+	res, err := b.callContract(ctx, call, block.Header(), state)
 	if err != nil {
 		return nil, err
 	}
-	rval, _, _, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), state)
-	return rval, err
+	if res.Failed() {
+		return nil, executionError(res)
+	}
+	return res.ReturnData, nil
 }
 
 // PendingCallContract executes a contract call on the pending state.
@@ -367,23 +812,52 @@ func (b *SimulatedBackend) PendingCallContract(ctx context.Context, call ethereu
 	defer b.mu.Unlock()
 	defer b.pendingState.RevertToSnapshot(b.pendingState.Snapshot())
 
-	rval, _, _, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
-	return rval, err
+	res, err := b.callContract(ctx, call, b.pendingBlock.Header(), b.pendingState)
+	if err != nil {
+		return nil, err
+	}
+	if res.Failed() {
+		return nil, executionError(res)
+	}
+	return res.ReturnData, nil
 }
 
 // PendingNonceAt implements PendingStateReader.PendingNonceAt, retrieving
-// the nonce currently pending for the account.
+// the nonce following the last executable transaction of the account in the pool.
 func (b *SimulatedBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	return b.pendingState.GetOrNewStateObject(account).Nonce(), nil
+	statedb, err := b.pendingParentState()
+	if err != nil {
+		return 0, err
+	}
+
+	nonce := statedb.GetNonce(account)
+	for b.pool[account][nonce] != nil {
+		nonce++
+	}
+	return nonce, nil
 }
 
-// SuggestGasPrice implements ContractTransactor.SuggestGasPrice. Since the simulated
-// chain doesn't have miners, we just return a gas price of 1 for any call.
+// SuggestGasPrice implements ContractTransactor.SuggestGasPrice.
+// It returns the base fee of the pending block plus the suggested tip,
+// which is enough for a legacy transaction to be included in the pending block.
 func (b *SimulatedBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
-	return big.NewInt(1), nil
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	baseFee := b.pendingBlock.BaseFee()
+	if baseFee == nil {
+		return new(big.Int).Set(suggestedGasTipCap), nil
+	}
+	return new(big.Int).Add(baseFee, suggestedGasTipCap), nil
+}
+
+// SuggestGasTipCap implements ContractTransactor.SuggestGasTipCap. Since the simulated
+// chain doesn't have competing transactions, we just return a tip of 1 wei.
+func (b *SimulatedBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(suggestedGasTipCap), nil
 }
 
 // EstimateGas executes the requested code against the currently pending block/state and
@@ -406,22 +880,22 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call ethereum.CallMs
 	cap = hi
 
 	// Create a helper to check if a gas allowance results in an executable transaction
-	executable := func(gas uint64) bool {
+	executable := func(gas uint64) (bool, *core.ExecutionResult) {
 		call.Gas = gas
 
 		snapshot := b.pendingState.Snapshot()
-		_, _, failed, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
+		res, err := b.callContract(ctx, call, b.pendingBlock.Header(), b.pendingState)
 		b.pendingState.RevertToSnapshot(snapshot)
 
-		if err != nil || failed {
-			return false
+		if err != nil || res.Failed() {
+			return false, res
 		}
-		return true
+		return true, res
 	}
 	// Execute the binary search and hone in on an executable gas limit
 	for lo+1 < hi {
 		mid := (hi + lo) / 2
-		if !executable(mid) {
+		if ok, _ := executable(mid); !ok {
 			lo = mid
 		} else {
 			hi = mid
@@ -429,7 +903,11 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call ethereum.CallMs
 	}
 	// Reject the transaction as invalid if it still fails at the highest allowance
 	if hi == cap {
-		if !executable(hi) {
+		if ok, res := executable(hi); !ok {
+			// the revert reason tells why the transaction would fail
+			if res != nil && res.Err != vm.ErrOutOfGas {
+				return 0, executionError(res)
+			}
 			return 0, errGasEstimationFailed
 		}
 	}
@@ -438,10 +916,27 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call ethereum.CallMs
 
 // callContract implements common code between normal and pending contract calls.
 // state is modified during execution, make sure to copy it if necessary.
-func (b *SimulatedBackend) callContract(ctx context.Context, call ethereum.CallMsg, block *types.Block, statedb *state.StateDB) ([]byte, uint64, bool, error) {
+func (b *SimulatedBackend) callContract(ctx context.Context, call ethereum.CallMsg, header *types.Header, statedb *state.StateDB) (*core.ExecutionResult, error) {
+	if call.GasPrice != nil && (call.GasFeeCap != nil || call.GasTipCap != nil) {
+		return nil, errors.New("both gasPrice and (maxFeePerGas or maxPriorityFeePerGas) specified")
+	}
 	// Ensure message is initialized properly.
-	if call.GasPrice == nil {
-		call.GasPrice = big.NewInt(1)
+	if call.GasPrice != nil || header.BaseFee == nil {
+		if call.GasPrice == nil {
+			call.GasPrice = new(big.Int)
+		}
+		call.GasFeeCap, call.GasTipCap = call.GasPrice, call.GasPrice
+	} else {
+		if call.GasFeeCap == nil {
+			call.GasFeeCap = new(big.Int)
+		}
+		if call.GasTipCap == nil {
+			call.GasTipCap = new(big.Int)
+		}
+		call.GasPrice = new(big.Int)
+		if call.GasFeeCap.BitLen() > 0 || call.GasTipCap.BitLen() > 0 {
+			call.GasPrice = math.BigMin(new(big.Int).Add(call.GasTipCap, header.BaseFee), call.GasFeeCap)
+		}
 	}
 	if call.Gas == 0 {
 		call.Gas = 50000000
@@ -453,45 +948,149 @@ func (b *SimulatedBackend) callContract(ctx context.Context, call ethereum.CallM
 	from := statedb.GetOrNewStateObject(call.From)
 	from.SetBalance(math.MaxBig256)
 	// Execute the call.
-	msg := callmsg{call}
+	msg := &core.Message{
+		From:              call.From,
+		To:                call.To,
+		Value:             call.Value,
+		GasLimit:          call.Gas,
+		GasPrice:          call.GasPrice,
+		GasFeeCap:         call.GasFeeCap,
+		GasTipCap:         call.GasTipCap,
+		Data:              call.Data,
+		AccessList:        call.AccessList,
+		SkipAccountChecks: true,
+	}
 
-	evmContext := core.NewEVMContext(msg, block.Header(), b.blockchain, nil)
 	// Create a new environment which holds all relevant information
 	// about the transaction and calling mechanisms.
-	vmenv := vm.NewEVM(evmContext, statedb, b.config, b.vmc)
+	// Calls don't have to pay the base fee, so a zero gas price is allowed.
+	vmc := b.vmc
+	vmc.NoBaseFee = true
+	vmenv := vm.NewEVM(core.NewEVMBlockContext(header, b.blockchain, nil), core.NewEVMTxContext(msg), statedb, b.config, vmc)
 	gaspool := new(core.GasPool).AddGas(math.MaxUint64)
 
-	return core.NewStateTransition(vmenv, msg, gaspool).TransitionDb()
+	return core.ApplyMessage(vmenv, msg, gaspool)
 }
 
-// SendTransaction updates the pending block to include the given transaction.
-// It panics if the transaction is invalid.
+// SendTransaction adds the given transaction to the pool.
+// Transaction is included in the pending block if it is executable and there is enough gas left,
+// transactions with a future nonce are queued until the nonce gap is filled.
+// A transaction with the same sender and nonce as a transaction in the pool replaces it,
+// if both its fee cap and tip are at least 10% higher (gas price for legacy transactions).
+// It returns an error if the transaction is invalid.
 func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	sender, err := types.Sender(types.NewEIP155Signer(b.config.ChainID), tx)
+	sender, err := b.validateTx(tx)
 	if err != nil {
-		panic(fmt.Errorf("invalid transaction: %v", err))
+		return err
 	}
-	nonce := b.pendingState.GetNonce(sender)
-	if tx.Nonce() != nonce {
-		panic(fmt.Errorf("invalid transaction nonce: got %d, want %d", tx.Nonce(), nonce))
+
+	if b.pool[sender] == nil {
+		b.pool[sender] = map[uint64]*types.Transaction{}
 	}
 
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
-		for _, tx := range b.pendingBlock.Transactions() {
-			block.AddTxWithChain(b.blockchain, tx)
+	old := b.pool[sender][tx.Nonce()]
+	if old != nil {
+		if old.Hash() == tx.Hash() {
+			return ErrAlreadyKnown
 		}
-		block.AddTxWithChain(b.blockchain, tx)
-	})
-	statedb, _ := b.blockchain.State()
+		feeCapThreshold := bumpPrice(old.GasFeeCap())
+		tipThreshold := bumpPrice(old.GasTipCap())
+		if tx.GasFeeCapIntCmp(feeCapThreshold) < 0 || tx.GasTipCapIntCmp(tipThreshold) < 0 {
+			return fmt.Errorf("%w: fee cap %s and tip %s, minimum %s and %s", ErrReplaceUnderpriced, tx.GasFeeCap(), tx.GasTipCap(), feeCapThreshold, tipThreshold)
+		}
+	}
 
-	b.pendingBlock = blocks[0]
-	b.pendingState, _ = state.New(b.pendingBlock.Root(), statedb.Database())
+	b.pool[sender][tx.Nonce()] = tx
+	if err := b.updatePending(); err != nil {
+		if old != nil {
+			b.pool[sender][tx.Nonce()] = old
+		} else {
+			delete(b.pool[sender], tx.Nonce())
+		}
+		return err
+	}
 	return nil
 }
 
+// bumpPrice returns the minimum price a replacement transaction has to pay.
+func bumpPrice(price *big.Int) *big.Int {
+	threshold := new(big.Int).Mul(price, big.NewInt(100+priceBump))
+	return threshold.Div(threshold, big.NewInt(100))
+}
+
+// validateTx checks if the transaction can be added to the pool,
+// returning the same errors the transaction pool of a node would.
+func (b *SimulatedBackend) validateTx(tx *types.Transaction) (common.Address, error) {
+	number := b.pendingBlock.Number()
+	switch tx.Type() {
+	case types.LegacyTxType:
+	case types.AccessListTxType:
+		if !b.config.IsBerlin(number) {
+			return common.Address{}, fmt.Errorf("%w: access list transactions require Berlin", ErrTxTypeNotSupported)
+		}
+	case types.DynamicFeeTxType:
+		if !b.config.IsLondon(number) {
+			return common.Address{}, fmt.Errorf("%w: dynamic fee transactions require London", ErrTxTypeNotSupported)
+		}
+	default:
+		return common.Address{}, fmt.Errorf("%w: type %d", ErrTxTypeNotSupported, tx.Type())
+	}
+	if tx.Value().Sign() < 0 {
+		return common.Address{}, ErrNegativeValue
+	}
+	if tx.GasFeeCapIntCmp(tx.GasTipCap()) < 0 {
+		return common.Address{}, fmt.Errorf("%w: tip %s, fee cap %s", ErrTipAboveFeeCap, tx.GasTipCap(), tx.GasFeeCap())
+	}
+	if baseFee := b.pendingBlock.BaseFee(); baseFee != nil && tx.GasFeeCapIntCmp(baseFee) < 0 {
+		return common.Address{}, fmt.Errorf("%w: fee cap %s, base fee %s", ErrFeeCapTooLow, tx.GasFeeCap(), baseFee)
+	}
+	if tx.Gas() > b.pendingBlock.GasLimit() {
+		return common.Address{}, fmt.Errorf("%w: transaction gas %d, block gas limit %d", ErrGasLimitExceeded, tx.Gas(), b.pendingBlock.GasLimit())
+	}
+
+	sender, err := b.sender(tx)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", ErrInvalidSender, err)
+	}
+
+	statedb, err := b.pendingParentState()
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	// EIP-3607: transactions can't be sent from contracts, unless they are impersonated
+	if _, impersonated := impersonatedSender(tx); !impersonated {
+		if codeHash := statedb.GetCodeHash(sender); codeHash != (common.Hash{}) && codeHash != types.EmptyCodeHash {
+			return common.Address{}, fmt.Errorf("%w: address %s", ErrSenderNoEOA, sender.Hex())
+		}
+	}
+
+	nonce := statedb.GetNonce(sender)
+	if tx.Nonce() < nonce {
+		return common.Address{}, fmt.Errorf("%w: got %d, want at least %d", ErrNonceTooLow, tx.Nonce(), nonce)
+	}
+	if tx.Nonce() >= nonce+maxNonceGap {
+		return common.Address{}, fmt.Errorf("%w: got %d, want less than %d", ErrNonceTooHigh, tx.Nonce(), nonce+maxNonceGap)
+	}
+
+	if balance := statedb.GetBalance(sender); balance.Cmp(tx.Cost()) < 0 {
+		return common.Address{}, fmt.Errorf("%w: balance %s, cost %s", ErrInsufficientFunds, balance, tx.Cost())
+	}
+
+	intrinsicGas, err := core.IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil, true, b.config.IsIstanbul(number), b.config.IsShanghai(number, b.pendingBlock.Time()))
+	if err != nil {
+		return common.Address{}, err
+	}
+	if tx.Gas() < intrinsicGas {
+		return common.Address{}, fmt.Errorf("%w: have %d, want %d", ErrIntrinsicGas, tx.Gas(), intrinsicGas)
+	}
+
+	return sender, nil
+}
+
 // FilterLogs executes a log filter operation, blocking during execution and
 // returning all the results in one batch.
 //
@@ -500,7 +1099,7 @@ func (b *SimulatedBackend) FilterLogs(ctx context.Context, query ethereum.Filter
 	var filter *filters.Filter
 	if query.BlockHash != nil {
 		// Block filter requested, construct a single-shot filter
-		filter = filters.NewBlockFilter(&filterBackend{b.database, b.blockchain}, *query.BlockHash, query.Addresses, query.Topics)
+		filter = b.filterSystem.NewBlockFilter(*query.BlockHash, query.Addresses, query.Topics)
 	} else {
 		// Initialize unset filter boundaried to run from genesis to chain head
 		from := int64(0)
@@ -512,7 +1111,7 @@ func (b *SimulatedBackend) FilterLogs(ctx context.Context, query ethereum.Filter
 			to = query.ToBlock.Int64()
 		}
 		// Construct the range filter
-		filter = filters.NewRangeFilter(&filterBackend{b.database, b.blockchain}, from, to, query.Addresses, query.Topics)
+		filter = b.filterSystem.NewRangeFilter(from, to, query.Addresses, query.Topics)
 	}
 	// Run the filter and return all the logs
 	logs, err := filter.Logs(ctx)
@@ -587,22 +1186,16 @@ func (b *SimulatedBackend) SubscribeNewHead(ctx context.Context, ch chan<- *type
 	}), nil
 }
 
-// AdjustTime adds a time shift to the simulated clock.
+// AdjustTime adds a time shift to the simulated clock of the pending block.
 func (b *SimulatedBackend) AdjustTime(adjustment time.Duration) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
-		for _, tx := range b.pendingBlock.Transactions() {
-			block.AddTx(tx)
-		}
-		block.OffsetTime(int64(adjustment.Seconds()))
-	})
-	statedb, _ := b.blockchain.State()
-
-	b.pendingBlock = blocks[0]
-	b.pendingState, _ = state.New(b.pendingBlock.Root(), statedb.Database())
-
+	b.pendingTimeOffset += int64(adjustment.Seconds())
+	if err := b.updatePending(); err != nil {
+		b.pendingTimeOffset -= int64(adjustment.Seconds())
+		return err
+	}
 	return nil
 }
 
@@ -611,63 +1204,70 @@ func (b *SimulatedBackend) Blockchain() *core.BlockChain {
 	return b.blockchain
 }
 
-// callmsg implements core.Message to allow passing it as a transaction simulator.
-type callmsg struct {
-	ethereum.CallMsg
-}
-
-func (m callmsg) From() common.Address { return m.CallMsg.From }
-func (m callmsg) Nonce() uint64        { return 0 }
-func (m callmsg) CheckNonce() bool     { return false }
-func (m callmsg) To() *common.Address  { return m.CallMsg.To }
-func (m callmsg) GasPrice() *big.Int   { return m.CallMsg.GasPrice }
-func (m callmsg) Gas() uint64          { return m.CallMsg.Gas }
-func (m callmsg) Value() *big.Int      { return m.CallMsg.Value }
-func (m callmsg) Data() []byte         { return m.CallMsg.Data }
-
 // filterBackend implements filters.Backend to support filtering for logs without
 // taking bloom-bits acceleration structures into account.
 type filterBackend struct {
-	db ethdb.Database
-	bc *core.BlockChain
+	db      ethdb.Database
+	bc      *core.BlockChain
+	backend *SimulatedBackend
 }
 
 func (fb *filterBackend) ChainDb() ethdb.Database  { return fb.db }
 func (fb *filterBackend) EventMux() *event.TypeMux { panic("not supported") }
 
 func (fb *filterBackend) HeaderByNumber(ctx context.Context, block rpc.BlockNumber) (*types.Header, error) {
-	if block == rpc.LatestBlockNumber {
+	switch block {
+	case rpc.PendingBlockNumber:
+		fb.backend.mu.Lock()
+		defer fb.backend.mu.Unlock()
+
+		if pending := fb.backend.pendingBlock; pending != nil {
+			return pending.Header(), nil
+		}
+		return nil, nil
+	case rpc.LatestBlockNumber:
 		return fb.bc.CurrentHeader(), nil
+	case rpc.FinalizedBlockNumber:
+		return fb.bc.CurrentFinalBlock(), nil
+	case rpc.SafeBlockNumber:
+		return fb.bc.CurrentSafeBlock(), nil
+	default:
+		return fb.bc.GetHeaderByNumber(uint64(block.Int64())), nil
 	}
-	return fb.bc.GetHeaderByNumber(uint64(block.Int64())), nil
 }
 
 func (fb *filterBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
 	return fb.bc.GetHeaderByHash(hash), nil
 }
 
-func (fb *filterBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
-	number := rawdb.ReadHeaderNumber(fb.db, hash)
-	if number == nil {
-		return nil, nil
+func (fb *filterBackend) GetBody(ctx context.Context, hash common.Hash, number rpc.BlockNumber) (*types.Body, error) {
+	if body := fb.bc.GetBody(hash); body != nil {
+		return body, nil
 	}
-	return rawdb.ReadReceipts(fb.db, hash, *number, fb.bc.Config()), nil
+	return nil, errors.New("block body not found")
 }
 
-func (fb *filterBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
+func (fb *filterBackend) PendingBlockAndReceipts() (*types.Block, types.Receipts) {
+	fb.backend.mu.Lock()
+	defer fb.backend.mu.Unlock()
+
+	return fb.backend.pendingBlock, fb.backend.pendingReceipts
+}
+
+func (fb *filterBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
 	number := rawdb.ReadHeaderNumber(fb.db, hash)
 	if number == nil {
 		return nil, nil
 	}
-	receipts := rawdb.ReadReceipts(fb.db, hash, *number, fb.bc.Config())
-	if receipts == nil {
+	header := rawdb.ReadHeader(fb.db, hash, *number)
+	if header == nil {
 		return nil, nil
 	}
-	logs := make([][]*types.Log, len(receipts))
-	for i, receipt := range receipts {
-		logs[i] = receipt.Logs
-	}
-	return logs, nil
+	return rawdb.ReadReceipts(fb.db, hash, *number, header.Time, fb.bc.Config()), nil
+}
+
+func (fb *filterBackend) GetLogs(ctx context.Context, hash common.Hash, number uint64) ([][]*types.Log, error) {
+	return rawdb.ReadLogs(fb.db, hash, number), nil
 }
 
 func (fb *filterBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
@@ -696,6 +1296,10 @@ func (fb *filterBackend) ServiceFilter(ctx context.Context, ms *bloombits.Matche
 	panic("not supported")
 }
 
+func (fb *filterBackend) ChainConfig() *params.ChainConfig { return fb.bc.Config() }
+
+func (fb *filterBackend) CurrentHeader() *types.Header { return fb.bc.CurrentHeader() }
+
 func nullSubscription() event.Subscription {
 	return event.NewSubscription(func(quit <-chan struct{}) error {
 		<-quit
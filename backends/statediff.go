@@ -0,0 +1,144 @@
+package backends
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// StateDiff contains the accounts changed by a transaction.
+type StateDiff map[common.Address]*AccountDiff
+
+// AccountDiff is the change of an account made by a transaction.
+type AccountDiff struct {
+	Before AccountState
+	After  AccountState
+	// Storage contains the values of the storage slots changed by the transaction.
+	Storage map[common.Hash]StorageDiff
+}
+
+// AccountState is the balance, nonce and code of an account.
+type AccountState struct {
+	Balance *big.Int
+	Nonce   uint64
+	Code    []byte
+}
+
+// StorageDiff is the value of a storage slot before and after a transaction.
+type StorageDiff struct {
+	Before common.Hash
+	After  common.Hash
+}
+
+// StateDiff re-executes the mined transaction and returns the balance, nonce, code
+// and storage slots of all accounts changed by it, including the fees paid by the sender
+// and received by the coinbase.
+func (b *SimulatedBackend) StateDiff(ctx context.Context, txHash common.Hash) (StateDiff, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r, err := b.stateBeforeTransaction(txHash)
+	if err != nil {
+		return nil, err
+	}
+	before := r.statedb.Copy()
+	tracer := newTouchTracer()
+	tracer.touch(r.block.Coinbase())
+	if _, err := r.apply(b.config, r.index, vm.Config{Tracer: tracer}); err != nil {
+		return nil, err
+	}
+	r.statedb.Finalise(b.config.IsEIP158(r.block.Number()))
+
+	diff := StateDiff{}
+	for address := range tracer.accounts {
+		d := &AccountDiff{
+			Before:  accountState(before, address),
+			After:   accountState(r.statedb, address),
+			Storage: map[common.Hash]StorageDiff{},
+		}
+		for slot := range tracer.slots[address] {
+			s := StorageDiff{Before: before.GetState(address, slot), After: r.statedb.GetState(address, slot)}
+			if s.Before != s.After {
+				d.Storage[slot] = s
+			}
+		}
+		if d.changed() {
+			diff[address] = d
+		}
+	}
+	return diff, nil
+}
+
+func (d *AccountDiff) changed() bool {
+	return d.Before.Balance.Cmp(d.After.Balance) != 0 ||
+		d.Before.Nonce != d.After.Nonce ||
+		!bytes.Equal(d.Before.Code, d.After.Code) ||
+		len(d.Storage) > 0
+}
+
+func accountState(statedb *state.StateDB, address common.Address) AccountState {
+	return AccountState{
+		Balance: statedb.GetBalance(address),
+		Nonce:   statedb.GetNonce(address),
+		Code:    statedb.GetCode(address),
+	}
+}
+
+// touchTracer records the accounts a transaction interacted with and the storage slots it wrote.
+// Accounts receiving value are touched even if no code is executed, e.g. beneficiaries of SELFDESTRUCT.
+type touchTracer struct {
+	accounts map[common.Address]struct{}
+	slots    map[common.Address]map[common.Hash]struct{}
+}
+
+func newTouchTracer() *touchTracer {
+	return &touchTracer{
+		accounts: map[common.Address]struct{}{},
+		slots:    map[common.Address]map[common.Hash]struct{}{},
+	}
+}
+
+func (t *touchTracer) touch(address common.Address) {
+	t.accounts[address] = struct{}{}
+}
+
+func (t *touchTracer) CaptureTxStart(gasLimit uint64) {}
+
+func (t *touchTracer) CaptureTxEnd(restGas uint64) {}
+
+func (t *touchTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.touch(from)
+	t.touch(to)
+}
+
+func (t *touchTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {}
+
+func (t *touchTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	t.touch(from)
+	t.touch(to)
+}
+
+func (t *touchTracer) CaptureExit(output []byte, gasUsed uint64, err error) {}
+
+func (t *touchTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if err != nil || len(scope.Stack.Data()) == 0 {
+		return
+	}
+	switch op {
+	case vm.SSTORE:
+		address := scope.Contract.Address()
+		if t.slots[address] == nil {
+			t.slots[address] = map[common.Hash]struct{}{}
+		}
+		t.slots[address][common.Hash(scope.Stack.Back(0).Bytes32())] = struct{}{}
+	case vm.SELFDESTRUCT:
+		t.touch(scope.Stack.Back(0).Bytes20())
+	}
+}
+
+func (t *touchTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
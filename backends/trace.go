@@ -0,0 +1,70 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
+	// registers the callTracer
+	_ "github.com/ethereum/go-ethereum/eth/tracers/native"
+)
+
+// TraceTransaction re-executes the mined transaction and returns its opcode level trace
+// in the format of geth's debug_traceTransaction with the default struct logger:
+// gas used, failure, return value and structLogs with pc, op, gas, gasCost, depth,
+// stack, memory and storage of every executed step.
+// Stack, memory, storage and return data capture can be configured with config, nil uses the defaults.
+func (b *SimulatedBackend) TraceTransaction(ctx context.Context, txHash common.Hash, config *logger.Config) (json.RawMessage, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tracer := logger.NewStructLogger(config)
+	if _, err := b.replayTransaction(txHash, vm.Config{Tracer: tracer}); err != nil {
+		return nil, err
+	}
+	return tracer.GetResult()
+}
+
+// CallFrame is a call made by a transaction in the format of geth's callTracer.
+// Calls contains the calls made by the frame in the order they were made.
+type CallFrame struct {
+	Type         string          `json:"type"`
+	From         common.Address  `json:"from"`
+	To           *common.Address `json:"to,omitempty"`
+	Value        *hexutil.Big    `json:"value,omitempty"`
+	Gas          hexutil.Uint64  `json:"gas"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	Input        hexutil.Bytes   `json:"input"`
+	Output       hexutil.Bytes   `json:"output,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	RevertReason string          `json:"revertReason,omitempty"`
+	Calls        []*CallFrame    `json:"calls,omitempty"`
+}
+
+// TraceCalls re-executes the mined transaction with geth's callTracer and returns
+// the tree of CALL, DELEGATECALL, STATICCALL, CALLCODE, CREATE and CREATE2 frames made by it.
+func (b *SimulatedBackend) TraceCalls(ctx context.Context, txHash common.Hash) (*CallFrame, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tracer, err := tracers.DefaultDirectory.New("callTracer", &tracers.Context{TxHash: txHash}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := b.replayTransaction(txHash, vm.Config{Tracer: tracer}); err != nil {
+		return nil, err
+	}
+	result, err := tracer.GetResult()
+	if err != nil {
+		return nil, err
+	}
+	frame := &CallFrame{}
+	if err := json.Unmarshal(result, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
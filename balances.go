@@ -0,0 +1,139 @@
+package ethertest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// balanceOfSelector is the selector of the ERC-20 balanceOf(address) function.
+var balanceOfSelector = []byte{0x70, 0xa0, 0x82, 0x31}
+
+// BalanceChange is an expected change of the ETH or ERC-20 token balance of an account.
+type BalanceChange struct {
+	Account common.Address
+	// Token is the address of the ERC-20 token, or nil for ETH.
+	Token *common.Address
+	Delta *big.Int
+}
+
+// EthChange creates a BalanceChange of the ETH balance of the account.
+func EthChange(account common.Address, delta *big.Int) BalanceChange {
+	return BalanceChange{Account: account, Delta: delta}
+}
+
+// TokenChange creates a BalanceChange of the balance of the account in the ERC-20 token.
+func TokenChange(token, account common.Address, delta *big.Int) BalanceChange {
+	return BalanceChange{Account: account, Token: &token, Delta: delta}
+}
+
+func (c BalanceChange) String() string {
+	if c.Token == nil {
+		return fmt.Sprintf("ETH balance of %s", c.Account.Hex())
+	}
+	return fmt.Sprintf("balance of %s in token %s", c.Account.Hex(), c.Token.Hex())
+}
+
+// ExpectBalanceChanges records the balances of the changes, executes f and commits the pending block.
+// It returns an error listing all balance changes if any of them doesn't match.
+// Gas paid for transactions mined in the meantime is excluded from the ETH balance change of their senders,
+// so only value transfers are compared.
+func (t *TestRig) ExpectBalanceChanges(be TestBackend, f func() error, changes ...BalanceChange) error {
+	start := be.Blockchain().CurrentHeader().Number
+	before := make([]*big.Int, len(changes))
+	for i, c := range changes {
+		b, err := balanceAt(be, c, start)
+		if err != nil {
+			return err
+		}
+		before[i] = b
+	}
+
+	if err := f(); err != nil {
+		return err
+	}
+	if err := be.Commit(); err != nil {
+		return err
+	}
+
+	end := be.Blockchain().CurrentHeader().Number
+	gasPaid, err := gasPaidBetween(be, start.Uint64()+1, end.Uint64())
+	if err != nil {
+		return err
+	}
+
+	matching := true
+	diff := &strings.Builder{}
+	for i, c := range changes {
+		after, err := balanceAt(be, c, end)
+		if err != nil {
+			return err
+		}
+		delta := new(big.Int).Sub(after, before[i])
+		if paid, found := gasPaid[c.Account]; found && c.Token == nil {
+			delta.Add(delta, paid)
+		}
+
+		if delta.Cmp(c.Delta) == 0 {
+			fmt.Fprintf(diff, "  %s: %s\n", c, formatDelta(delta))
+			continue
+		}
+		matching = false
+		fmt.Fprintf(diff, "x %s: expected %s, got %s\n", c, formatDelta(c.Delta), formatDelta(delta))
+	}
+	if matching {
+		return nil
+	}
+	return fmt.Errorf("balance changes don't match:\n%s", diff)
+}
+
+func balanceAt(be TestBackend, c BalanceChange, blockNumber *big.Int) (*big.Int, error) {
+	if c.Token == nil {
+		return be.BalanceAt(context.Background(), c.Account, blockNumber)
+	}
+
+	data := append(append([]byte{}, balanceOfSelector...), common.LeftPadBytes(c.Account.Bytes(), 32)...)
+	out, err := be.CallContract(context.Background(), ethereum.CallMsg{To: c.Token, Data: data}, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) != 32 {
+		return nil, fmt.Errorf("balanceOf of token %s returned %d bytes", c.Token.Hex(), len(out))
+	}
+	return new(big.Int).SetBytes(out), nil
+}
+
+// gasPaidBetween returns the gas fees paid by the senders of transactions in the blocks from first to last.
+func gasPaidBetween(be TestBackend, first, last uint64) (map[common.Address]*big.Int, error) {
+	paid := map[common.Address]*big.Int{}
+	for n := first; n <= last; n++ {
+		block := be.Blockchain().GetBlockByNumber(n)
+		if block == nil {
+			return nil, fmt.Errorf("block %d does not exist", n)
+		}
+		receipts := be.Blockchain().GetReceiptsByHash(block.Hash())
+		for i, tx := range block.Transactions() {
+			sender, err := be.TransactionSender(tx)
+			if err != nil {
+				return nil, err
+			}
+			fee := new(big.Int).Mul(new(big.Int).SetUint64(receipts[i].GasUsed), receipts[i].EffectiveGasPrice)
+			if paid[sender] == nil {
+				paid[sender] = new(big.Int)
+			}
+			paid[sender].Add(paid[sender], fee)
+		}
+	}
+	return paid, nil
+}
+
+func formatDelta(d *big.Int) string {
+	if d.Sign() > 0 {
+		return "+" + d.String()
+	}
+	return d.String()
+}
@@ -0,0 +1,67 @@
+package ethertest_test
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/stretchr/testify/require"
+	"github.com/tokencard/ethertest"
+)
+
+// tokenCode returns the storage slot of the account as balanceOf(account).
+var tokenCode = []byte{0x60, 0x04, 0x35, 0x54, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}
+
+func TestExpectBalanceChanges(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+	var whale = common.HexToAddress("0x00000000219ab540356cBB839Cbe05303d7705Fa")
+	var token = common.HexToAddress("0x7e")
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddGenesisAccountAllocation(whale, ethertest.EthToWei(100))
+	tr.AddGenesisAllocation(token, core.GenesisAccount{Code: tokenCode})
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+	be.Impersonate(whale)
+
+	transfers := func() error {
+		if err := owner.Transfer(be, receiver.Address(), ethertest.EthToWei(1)); err != nil {
+			return err
+		}
+		opts := ethertest.ImpersonatedTransactOpts(be, whale, ethertest.WithGasLimit(21000))
+		opts.Value = ethertest.EthToWei(2)
+		if _, err := bind.NewBoundContract(receiver.Address(), abi.ABI{}, be, be, be).Transfer(opts); err != nil {
+			return err
+		}
+		return be.SetStorageAt(token, common.BytesToHash(receiver.Address().Bytes()), common.BigToHash(big.NewInt(100)))
+	}
+
+	require.Nil(tr.ExpectBalanceChanges(be, transfers,
+		ethertest.EthChange(owner.Address(), ethertest.EthToWei(-1)),
+		ethertest.EthChange(whale, ethertest.EthToWei(-2)),
+		ethertest.EthChange(receiver.Address(), ethertest.EthToWei(3)),
+		ethertest.TokenChange(token, receiver.Address(), big.NewInt(100)),
+		ethertest.TokenChange(token, owner.Address(), big.NewInt(0)),
+	))
+
+	err := tr.ExpectBalanceChanges(be, transfers,
+		ethertest.EthChange(owner.Address(), ethertest.EthToWei(-1)),
+		ethertest.EthChange(receiver.Address(), ethertest.EthToWei(1)),
+		ethertest.TokenChange(token, receiver.Address(), big.NewInt(100)),
+	)
+	require.NotNil(err)
+	require.Contains(err.Error(), "  ETH balance of "+owner.Address().Hex()+": -1000000000000000000\n")
+	require.Contains(err.Error(), "x ETH balance of "+receiver.Address().Hex()+": expected +1000000000000000000, got +3000000000000000000\n")
+	require.Contains(err.Error(), "x balance of "+receiver.Address().Hex()+" in token "+token.Hex()+": expected +100, got 0\n")
+
+	failed := errors.New("failed")
+	require.Equal(failed, tr.ExpectBalanceChanges(be, func() error { return failed }))
+}
@@ -0,0 +1,158 @@
+package ethertest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/tokencard/ethertest/backends"
+)
+
+// CallTrace returns the tree of calls made by the mined transaction,
+// one call per line with the contract name, the function name, arguments,
+// return values and revert reasons decoded using the ABIs of the registered contracts:
+//
+//	CALL 0x... → test.sol:Wallet(0x...).transfer(to: 0x..., amount: 100) (gas used: 51234)
+//	└─ CALL test.sol:Token(0x...).transfer(to: 0x..., value: 100) → (true) (gas used: 29811)
+func (t *TestRig) CallTrace(be TestBackend, tx *types.Transaction) (string, error) {
+	frame, err := be.TraceCalls(context.Background(), tx.Hash())
+	if err != nil {
+		return "", err
+	}
+	return t.FormatCalls(frame), nil
+}
+
+// PrintCallTrace writes the CallTrace of the mined transaction to w.
+func (t *TestRig) PrintCallTrace(w io.Writer, be TestBackend, tx *types.Transaction) error {
+	trace, err := t.CallTrace(be, tx)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, trace)
+	return err
+}
+
+// FormatCalls formats the tree of calls like CallTrace.
+func (t *TestRig) FormatCalls(frame *backends.CallFrame) string {
+	s := &strings.Builder{}
+	fmt.Fprintf(s, "%s %s → %s\n", frame.Type, frame.From.Hex(), t.formatCall(frame))
+	t.formatCalls(s, frame.Calls, "")
+	return s.String()
+}
+
+func (t *TestRig) formatCalls(s *strings.Builder, calls []*backends.CallFrame, prefix string) {
+	for i, c := range calls {
+		branch, indent := "├─ ", "│  "
+		if i == len(calls)-1 {
+			branch, indent = "└─ ", "   "
+		}
+		fmt.Fprintf(s, "%s%s%s %s\n", prefix, branch, c.Type, t.formatCall(c))
+		t.formatCalls(s, c.Calls, prefix+indent)
+	}
+}
+
+// formatCall formats the callee, the decoded input and output, the value and the gas used of the call.
+func (t *TestRig) formatCall(frame *backends.CallFrame) string {
+	s := &strings.Builder{}
+
+	var contractABI *abi.ABI
+	if frame.To != nil {
+		s.WriteString(t.formatAddress(*frame.To))
+		if c := t.contractAt(*frame.To); c != nil {
+			contractABI = c.abi
+		}
+	}
+
+	create := frame.Type == vm.CREATE.String() || frame.Type == vm.CREATE2.String()
+	method := t.findMethod(contractABI, frame.Input)
+	switch {
+	case create:
+	case method != nil:
+		args, err := method.Inputs.Unpack(frame.Input[4:])
+		if err != nil {
+			fmt.Fprintf(s, ".%s(%s)", method.Name, hexutil.Encode(frame.Input[4:]))
+		} else {
+			fmt.Fprintf(s, ".%s(%s)", method.Name, formatArguments(method.Inputs, args))
+		}
+	case len(frame.Input) >= 4:
+		fmt.Fprintf(s, ".%s(%s)", hexutil.Encode(frame.Input[:4]), hexutil.Encode(frame.Input[4:]))
+	case len(frame.Input) > 0:
+		fmt.Fprintf(s, ".fallback(%s)", hexutil.Encode(frame.Input))
+	}
+
+	if frame.Value != nil && frame.Value.ToInt().Sign() != 0 {
+		fmt.Fprintf(s, " {value: %s}", frame.Value.ToInt())
+	}
+
+	if frame.Error == "" && !create && method != nil && len(method.Outputs) > 0 {
+		if values, err := method.Outputs.Unpack(frame.Output); err == nil {
+			fmt.Fprintf(s, " → (%s)", formatArguments(method.Outputs, values))
+		} else {
+			fmt.Fprintf(s, " → %s", hexutil.Encode(frame.Output))
+		}
+	}
+
+	if frame.Error == vm.ErrExecutionReverted.Error() {
+		fmt.Fprintf(s, " reverted with %s", t.describeRevert(revertFromData(frame.Output)))
+	} else if frame.Error != "" {
+		fmt.Fprintf(s, " failed with %q", frame.Error)
+	}
+
+	fmt.Fprintf(s, " (gas used: %d)", uint64(frame.GasUsed))
+	return s.String()
+}
+
+// contractAt returns the registered contract executed at the address, nil if there is none.
+func (t *TestRig) contractAt(address common.Address) *contract {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	names := []string{}
+	for n, c := range t.contracts {
+		if _, found := c.addresses[address]; found {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return t.contracts[names[0]]
+}
+
+// formatAddress formats the address with the name of the registered contract executed at it.
+func (t *TestRig) formatAddress(address common.Address) string {
+	if c := t.contractAt(address); c != nil {
+		return fmt.Sprintf("%s(%s)", c.name, address.Hex())
+	}
+	return address.Hex()
+}
+
+// findMethod returns the method called by the input, preferring the ABI of the called contract
+// over the ABIs of other registered contracts.
+func (t *TestRig) findMethod(contractABI *abi.ABI, input []byte) *abi.Method {
+	if len(input) < 4 {
+		return nil
+	}
+	abis := t.registeredABIs()
+	if contractABI != nil {
+		abis = append([]abi.ABI{*contractABI}, abis...)
+	}
+	for _, a := range abis {
+		for _, m := range a.Methods {
+			if bytes.Equal(m.ID, input[:4]) {
+				method := m
+				return &method
+			}
+		}
+	}
+	return nil
+}
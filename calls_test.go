@@ -0,0 +1,73 @@
+package ethertest_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tokencard/ethertest"
+)
+
+func TestCallTrace(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+	tr.AddCoverageForContracts("./test/build/forwarder/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	c, _, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+	f, _, err := tr.DeployContract(be, owner.TransactOpts(be), "forwarder.sol:Forwarder", c.Address())
+	require.Nil(err)
+	require.Nil(be.Commit())
+	forwarder := f.Address()
+	target, err := f.Call("target")
+	require.Nil(err)
+	require.Equal([]interface{}{c.Address()}, target)
+
+	forwarded, err := tr.ContractAt(be, "test.sol:Test", forwarder)
+	require.Nil(err)
+
+	tx, err := forwarded.Transact(owner.TransactOpts(be, ethertest.WithGasLimit(200000)), "setValue", "new value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	frame, err := be.TraceCalls(context.Background(), tx.Hash())
+	require.Nil(err)
+	require.Equal("CALL", frame.Type)
+	require.Equal(owner.Address(), frame.From)
+	require.Equal(forwarder, *frame.To)
+	require.Len(frame.Calls, 1)
+	require.Equal(c.Address(), *frame.Calls[0].To)
+	require.Less(uint64(frame.Calls[0].GasUsed), uint64(frame.GasUsed))
+
+	trace, err := tr.CallTrace(be, tx)
+	require.Nil(err)
+	lines := strings.Split(trace, "\n")
+	require.Len(lines, 3)
+	require.True(strings.HasPrefix(lines[0], "CALL "+owner.Address().Hex()+" → forwarder.sol:Forwarder("+forwarder.Hex()+`).setValue(_value: "new value") (gas used: `), lines[0])
+	require.True(strings.HasPrefix(lines[1], "└─ CALL test.sol:Test("+c.Address().Hex()+`).setValue(_value: "new value") (gas used: `), lines[1])
+
+	tx, err = forwarded.Transact(owner.TransactOpts(be, ethertest.WithGasLimit(200000)), "value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+	trace, err = tr.CallTrace(be, tx)
+	require.Nil(err)
+	require.Contains(trace, `.value() → ("new value") (gas used: `)
+
+	tx, err = forwarded.Transact(owner.TransactOpts(be, ethertest.WithGasLimit(200000)), "willFail")
+	require.Nil(err)
+	require.Nil(be.Commit())
+	out := &bytes.Buffer{}
+	require.Nil(tr.PrintCallTrace(out, be, tx))
+	require.Contains(out.String(), `.willFail() reverted with "will fail" (gas used: `)
+	require.Equal(2, strings.Count(out.String(), `reverted with "will fail"`))
+	require.Equal(100.0, tr.CoverageOf("forwarder.sol"))
+}
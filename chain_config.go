@@ -0,0 +1,159 @@
+package ethertest
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Hardfork is a named set of EVM and protocol rules.
+type Hardfork string
+
+// Hardforks supported by TestBackend, in the order of their activation on the mainnet.
+const (
+	Byzantium      Hardfork = "byzantium"
+	Constantinople Hardfork = "constantinople"
+	Petersburg     Hardfork = "petersburg"
+	Istanbul       Hardfork = "istanbul"
+	Berlin         Hardfork = "berlin"
+	London         Hardfork = "london"
+	Shanghai       Hardfork = "shanghai"
+	Cancun         Hardfork = "cancun"
+)
+
+var hardforks = []Hardfork{Byzantium, Constantinople, Petersburg, Istanbul, Berlin, London, Shanghai, Cancun}
+
+// isTimestampFork returns true for hardforks that are activated by block timestamp instead of block number.
+func (h Hardfork) isTimestampFork() bool {
+	return h == Shanghai || h == Cancun
+}
+
+func (h Hardfork) index() int {
+	for i, f := range hardforks {
+		if f == h {
+			return i
+		}
+	}
+	return -1
+}
+
+// WithHardfork sets the hardfork the blockchain starts with.
+// All hardforks up to and including it are active from the genesis block, later ones are disabled
+// unless their activation is set with WithForkBlock or WithForkTime.
+// If not set, it will default to London.
+func WithHardfork(fork Hardfork) func(*backendOptions) {
+	return func(opt *backendOptions) {
+		opt.hardfork = fork
+	}
+}
+
+// WithForkBlock sets the block number at which a hardfork activates.
+// Shanghai and later hardforks are activated by block timestamp and have to be set with WithForkTime.
+func WithForkBlock(fork Hardfork, block uint64) func(*backendOptions) {
+	return func(opt *backendOptions) {
+		opt.forkBlocks[fork] = block
+	}
+}
+
+// WithForkTime sets the block timestamp at which a hardfork activates.
+// Only Shanghai and later hardforks are activated by block timestamp.
+func WithForkTime(fork Hardfork, t time.Time) func(*backendOptions) {
+	return func(opt *backendOptions) {
+		opt.forkTimes[fork] = uint64(t.Unix())
+	}
+}
+
+// WithChainID sets the chain id used for replay protected transactions.
+// If not set, it will default to 1337.
+func WithChainID(chainID *big.Int) func(*backendOptions) {
+	return func(opt *backendOptions) {
+		opt.chainID = chainID
+	}
+}
+
+// chainConfig creates the chain config for the selected hardfork and activation points.
+func (opt *backendOptions) chainConfig() (*params.ChainConfig, error) {
+	last := opt.hardfork.index()
+	if last == -1 {
+		return nil, fmt.Errorf("unknown hardfork %q", opt.hardfork)
+	}
+
+	activation := func(fork Hardfork) *big.Int {
+		if block, found := opt.forkBlocks[fork]; found {
+			return new(big.Int).SetUint64(block)
+		}
+		if fork.index() <= last {
+			return big.NewInt(0)
+		}
+		return nil
+	}
+	activationTime := func(fork Hardfork) *uint64 {
+		if t, found := opt.forkTimes[fork]; found {
+			return &t
+		}
+		if fork.index() <= last {
+			t := uint64(0)
+			return &t
+		}
+		return nil
+	}
+
+	for fork := range opt.forkBlocks {
+		if fork.index() == -1 {
+			return nil, fmt.Errorf("unknown hardfork %q", fork)
+		}
+		if fork.isTimestampFork() {
+			return nil, fmt.Errorf("%s is activated by block timestamp, use WithForkTime", fork)
+		}
+	}
+	for fork := range opt.forkTimes {
+		if fork.index() == -1 {
+			return nil, fmt.Errorf("unknown hardfork %q", fork)
+		}
+		if !fork.isTimestampFork() {
+			return nil, fmt.Errorf("%s is activated by block number, use WithForkBlock", fork)
+		}
+	}
+
+	chainID := params.AllEthashProtocolChanges.ChainID
+	if opt.chainID != nil {
+		chainID = opt.chainID
+	}
+
+	config := &params.ChainConfig{
+		ChainID:             new(big.Int).Set(chainID),
+		HomesteadBlock:      big.NewInt(0),
+		EIP150Block:         big.NewInt(0),
+		EIP155Block:         big.NewInt(0),
+		EIP158Block:         big.NewInt(0),
+		ByzantiumBlock:      activation(Byzantium),
+		ConstantinopleBlock: activation(Constantinople),
+		PetersburgBlock:     activation(Petersburg),
+		IstanbulBlock:       activation(Istanbul),
+		MuirGlacierBlock:    activation(Istanbul),
+		BerlinBlock:         activation(Berlin),
+		LondonBlock:         activation(London),
+		ArrowGlacierBlock:   activation(London),
+		GrayGlacierBlock:    activation(London),
+		ShanghaiTime:        activationTime(Shanghai),
+		CancunTime:          activationTime(Cancun),
+		Ethash:              new(params.EthashConfig),
+	}
+
+	// withdrawals and blobs only exist on proof-of-stake chains,
+	// so the chain is merged from the genesis block, which requires London rules
+	if config.ShanghaiTime != nil {
+		if config.LondonBlock == nil || config.LondonBlock.Sign() != 0 {
+			return nil, fmt.Errorf("%s requires %s to be active from the genesis block", Shanghai, London)
+		}
+		config.TerminalTotalDifficulty = big.NewInt(0)
+		config.TerminalTotalDifficultyPassed = true
+	}
+
+	if err := config.CheckConfigForkOrder(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
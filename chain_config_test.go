@@ -0,0 +1,166 @@
+package ethertest_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+	"github.com/tokencard/ethertest"
+)
+
+func TestHardfork(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+
+	require := require.New(t)
+
+	send := func(be ethertest.TestBackend, tx types.TxData) error {
+		signer := types.LatestSignerForChainID(be.Blockchain().Config().ChainID)
+		signed, err := types.SignTx(types.NewTx(tx), signer, owner.PrivKey())
+		require.Nil(err)
+		return be.SendTransaction(context.Background(), signed)
+	}
+
+	accessListTx := &types.AccessListTx{Gas: 30000, GasPrice: ethertest.GweiToWei(2), To: &common.Address{}}
+	dynamicFeeTx := &types.DynamicFeeTx{Gas: 21000, GasFeeCap: ethertest.GweiToWei(2), GasTipCap: ethertest.GweiToWei(1), To: &common.Address{}}
+
+	be := tr.NewTestBackend(ethertest.WithHardfork(ethertest.Istanbul))
+	defer be.Close()
+
+	head, err := be.HeaderByNumber(context.Background(), nil)
+	require.Nil(err)
+	require.Nil(head.BaseFee)
+	require.True(errors.Is(send(be, accessListTx), ethertest.ErrTxTypeNotSupported))
+	require.True(errors.Is(send(be, dynamicFeeTx), ethertest.ErrTxTypeNotSupported))
+	require.Nil(owner.Transfer(be, receiver.Address(), ethertest.EthToWei(1)))
+
+	be = tr.NewTestBackend(ethertest.WithHardfork(ethertest.Berlin))
+	defer be.Close()
+
+	require.Nil(send(be, accessListTx))
+	require.True(errors.Is(send(be, dynamicFeeTx), ethertest.ErrTxTypeNotSupported))
+
+	be = tr.NewTestBackend(ethertest.WithHardfork(ethertest.Cancun))
+	defer be.Close()
+
+	require.Nil(send(be, dynamicFeeTx))
+	require.Nil(be.Commit())
+	require.Nil(owner.Transfer(be, receiver.Address(), ethertest.EthToWei(1)))
+	head = be.Blockchain().CurrentHeader()
+	require.True(be.Blockchain().Config().IsCancun(head.Number, head.Time))
+	require.Equal(0, head.Difficulty.Sign())
+}
+
+func TestForkActivation(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+
+	require := require.New(t)
+	be := tr.NewTestBackend(
+		ethertest.WithHardfork(ethertest.Istanbul),
+		ethertest.WithForkBlock(ethertest.Berlin, 2),
+		ethertest.WithForkBlock(ethertest.London, 2),
+	)
+	defer be.Close()
+
+	config := be.Blockchain().Config()
+	for i := 0; i < 3; i++ {
+		require.Nil(owner.Transfer(be, receiver.Address(), ethertest.EthToWei(1)))
+
+		head := be.Blockchain().CurrentHeader()
+		london := head.Number.Uint64() >= 2
+		require.Equal(london, head.BaseFee != nil)
+		require.Equal(london, config.IsLondon(head.Number))
+	}
+
+	genesis := time.Unix(1000, 0)
+	shanghai := genesis.Add(time.Minute)
+	be = tr.NewTestBackend(ethertest.WithBlockchainTime(genesis), ethertest.WithForkTime(ethertest.Shanghai, shanghai))
+	defer be.Close()
+
+	config = be.Blockchain().Config()
+	for i := 0; i < 8; i++ {
+		require.Nil(owner.Transfer(be, receiver.Address(), ethertest.EthToWei(1)))
+
+		head := be.Blockchain().CurrentHeader()
+		require.Equal(head.Time >= uint64(shanghai.Unix()), config.IsShanghai(head.Number, head.Time))
+	}
+	head := be.Blockchain().CurrentHeader()
+	require.True(config.IsShanghai(head.Number, head.Time))
+}
+
+func TestGasRepricing(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+
+	gasUsed := func(fork ethertest.Hardfork) uint64 {
+		be := tr.NewTestBackend(ethertest.WithHardfork(fork))
+		defer be.Close()
+
+		c, _, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+		require.Nil(err)
+		require.Nil(be.Commit())
+
+		tx, err := c.Transact(owner.TransactOpts(be), "setValue", "new value")
+		require.Nil(err)
+		require.Nil(be.Commit())
+
+		receipt, err := be.TransactionReceipt(context.Background(), tx.Hash())
+		require.Nil(err)
+		require.Equal(types.ReceiptStatusSuccessful, receipt.Status)
+		return receipt.GasUsed
+	}
+
+	// Berlin made the first access of a storage slot more expensive
+	require.NotEqual(gasUsed(ethertest.Istanbul), gasUsed(ethertest.Berlin))
+}
+
+func TestChainID(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+
+	require := require.New(t)
+	be := tr.NewTestBackend(ethertest.WithChainID(big.NewInt(5)))
+	defer be.Close()
+
+	require.Equal(big.NewInt(5), be.Blockchain().Config().ChainID)
+	require.Nil(owner.Transfer(be, receiver.Address(), ethertest.EthToWei(1)))
+
+	tx := types.NewTransaction(1, receiver.Address(), ethertest.EthToWei(1), 21000, ethertest.GweiToWei(2), nil)
+	tx, err := types.SignTx(tx, types.NewEIP155Signer(big.NewInt(1337)), owner.PrivKey())
+	require.Nil(err)
+	require.True(errors.Is(be.SendTransaction(context.Background(), tx), ethertest.ErrInvalidSender))
+}
+
+func TestInvalidHardfork(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+
+	require := require.New(t)
+	require.Panics(func() { tr.NewTestBackend(ethertest.WithHardfork("frontier")) })
+	require.Panics(func() { tr.NewTestBackend(ethertest.WithForkBlock(ethertest.Shanghai, 1)) })
+	require.Panics(func() {
+		tr.NewTestBackend(ethertest.WithHardfork(ethertest.Istanbul), ethertest.WithForkBlock(ethertest.London, 1))
+	})
+	require.Panics(func() {
+		tr.NewTestBackend(ethertest.WithForkBlock(ethertest.London, 1), ethertest.WithHardfork(ethertest.Shanghai))
+	})
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
+	"github.com/tokencard/ethertest"
+)
+
+// contextLines is the number of source lines printed before and after the current line.
+const contextLines = 2
+
+// debugger steps through the source code of a trace saved with TestRig.SaveTrace.
+type debugger struct {
+	trace *ethertest.Trace
+	// lines contains the source lines of the contracts of the trace.
+	lines [][]string
+	// structLogs is the opcode level trace of a transaction, if one was loaded.
+	structLogs []logger.StructLogRes
+	// opcodes maps steps to the index of their first instruction in structLogs, -1 if there is none.
+	opcodes []int
+	// executions maps steps to the index of their execution.
+	executions  []int
+	current     int
+	breakpoints map[string]bool
+	w           io.Writer
+}
+
+func newDebugger(trace *ethertest.Trace, structLogs []logger.StructLogRes, out io.Writer) (*debugger, error) {
+	if len(trace.Steps) == 0 {
+		return nil, fmt.Errorf("trace has no steps")
+	}
+	d := &debugger{
+		trace:       trace,
+		structLogs:  structLogs,
+		breakpoints: map[string]bool{},
+		w:           out,
+	}
+	for _, c := range trace.Contracts {
+		d.lines = append(d.lines, strings.Split(c.Source, "\n"))
+	}
+	d.executions = make([]int, len(trace.Steps))
+	// the executions are sorted by their first steps, empty executions share them with the next one
+	for i, j := 0, 0; j < len(trace.Steps); j++ {
+		for i+1 < len(trace.Executions) && j == trace.Executions[i+1].Step {
+			i++
+		}
+		d.executions[j] = i
+	}
+	d.matchStructLogs()
+	return d, nil
+}
+
+// matchStructLogs maps the steps to instructions of the opcode level trace by their program counters and call depths.
+// The trace usually contains more executions than the transaction of the opcode level trace,
+// so the steps of the (latest) execution matching most instructions are used.
+func (d *debugger) matchStructLogs() {
+	d.opcodes = make([]int, len(d.trace.Steps))
+	for i := range d.opcodes {
+		d.opcodes[i] = -1
+	}
+	if len(d.structLogs) == 0 {
+		return
+	}
+
+	executions := []int{}
+	for _, e := range d.trace.Executions {
+		executions = append(executions, e.Step)
+	}
+	if len(executions) == 0 {
+		// traces saved before executions were recorded
+		executions = []int{0}
+	}
+	var best []int
+	bestStart := 0
+	for i, start := range executions {
+		end := len(d.trace.Steps)
+		if i+1 < len(executions) {
+			end = executions[i+1]
+		}
+		if matched := d.match(start, end); len(matched) >= len(best) && len(matched) > 0 {
+			best, bestStart = matched, start
+		}
+	}
+	for i, opcode := range best {
+		d.opcodes[bestStart+i] = opcode
+	}
+}
+
+// match returns the instructions of the steps from the start to the end, until a step doesn't match an instruction.
+func (d *debugger) match(start, end int) []int {
+	opcodes := []int{}
+	cursor := 0
+	for _, step := range d.trace.Steps[start:end] {
+		found := -1
+		for j := cursor; j < len(d.structLogs); j++ {
+			if d.structLogs[j].Pc == step.PC() && d.structLogs[j].Depth == step.Depth() {
+				found = j
+				break
+			}
+		}
+		if found == -1 {
+			break
+		}
+		opcodes = append(opcodes, found)
+		cursor = found + 1
+	}
+	return opcodes
+}
+
+// location returns the contract name and the line of the step.
+func (d *debugger) location(i int) (string, int) {
+	name, line, _ := d.trace.Location(i)
+	return name, line
+}
+
+// sameStatement reports if the steps are on the same source line and call depth of the same execution.
+func (d *debugger) sameStatement(i, j int) bool {
+	ni, li := d.location(i)
+	nj, lj := d.location(j)
+	return ni == nj && li == lj && d.trace.Steps[i].Depth() == d.trace.Steps[j].Depth() && d.executions[i] == d.executions[j]
+}
+
+func (d *debugger) atBreakpoint(i int) bool {
+	name, line := d.location(i)
+	return d.breakpoints[fmt.Sprintf("%s:%d", name, line)]
+}
+
+// step moves to the next statement, entering calls.
+func (d *debugger) step() bool {
+	for i := d.current + 1; i < len(d.trace.Steps); i++ {
+		if !d.sameStatement(d.current, i) {
+			d.current = i
+			return true
+		}
+	}
+	return false
+}
+
+// next moves to the next statement in the same or a calling function, stepping over calls.
+func (d *debugger) next() bool {
+	depth := d.trace.Steps[d.current].Depth()
+	for i := d.current + 1; i < len(d.trace.Steps); i++ {
+		if d.trace.Steps[i].Depth() <= depth && !d.sameStatement(d.current, i) {
+			d.current = i
+			return true
+		}
+	}
+	return false
+}
+
+// back moves to the beginning of the previous statement.
+func (d *debugger) back() bool {
+	i := d.current - 1
+	for i >= 0 && d.sameStatement(d.current, i) {
+		i--
+	}
+	if i < 0 {
+		return false
+	}
+	for i > 0 && d.sameStatement(i, i-1) {
+		i--
+	}
+	d.current = i
+	return true
+}
+
+// stepOut moves to the first statement after the current call returned.
+func (d *debugger) stepOut() (bool, error) {
+	depth := d.trace.Steps[d.current].Depth()
+	if depth == 0 {
+		return false, fmt.Errorf("call depth is not recorded in the trace")
+	}
+	for i := d.current + 1; i < len(d.trace.Steps); i++ {
+		if d.trace.Steps[i].Depth() < depth {
+			d.current = i
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// continueTo moves to the next (or previous) statement with a breakpoint.
+func (d *debugger) continueTo(direction int) bool {
+	for i := d.current + direction; i >= 0 && i < len(d.trace.Steps); i += direction {
+		if !d.sameStatement(d.current, i) && d.atBreakpoint(i) {
+			d.current = i
+			if direction < 0 {
+				for d.current > 0 && d.sameStatement(d.current, d.current-1) {
+					d.current--
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// execute runs a debugger command, returning false if the debugger should quit.
+func (d *debugger) execute(command string) bool {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return true
+	}
+
+	moved := func(ok bool) {
+		if ok {
+			d.printLocation()
+		} else {
+			fmt.Fprintln(d.w, "end of trace")
+		}
+	}
+	movedBack := func(ok bool) {
+		if ok {
+			d.printLocation()
+		} else {
+			fmt.Fprintln(d.w, "start of trace")
+		}
+	}
+
+	switch fields[0] {
+	case "s", "step":
+		moved(d.step())
+	case "n", "next":
+		moved(d.next())
+	case "b", "back":
+		movedBack(d.back())
+	case "o", "out":
+		ok, err := d.stepOut()
+		if err != nil {
+			fmt.Fprintln(d.w, err)
+			return true
+		}
+		moved(ok)
+	case "c", "continue":
+		moved(d.continueTo(1))
+	case "rc", "reverse":
+		movedBack(d.continueTo(-1))
+	case "g", "goto":
+		if len(fields) != 2 {
+			fmt.Fprintln(d.w, "usage: goto <step>")
+			return true
+		}
+		i, err := strconv.Atoi(fields[1])
+		if err != nil || i < 0 || i >= len(d.trace.Steps) {
+			fmt.Fprintf(d.w, "step has to be between 0 and %d\n", len(d.trace.Steps)-1)
+			return true
+		}
+		d.current = i
+		d.printLocation()
+	case "break", "clear":
+		if len(fields) != 2 || !strings.Contains(fields[1], ":") {
+			fmt.Fprintf(d.w, "usage: %s <file>:<line>\n", fields[0])
+			return true
+		}
+		if fields[0] == "break" {
+			d.breakpoints[fields[1]] = true
+		} else {
+			delete(d.breakpoints, fields[1])
+		}
+	case "breakpoints":
+		breakpoints := []string{}
+		for b := range d.breakpoints {
+			breakpoints = append(breakpoints, b)
+		}
+		sort.Strings(breakpoints)
+		for _, b := range breakpoints {
+			fmt.Fprintln(d.w, b)
+		}
+	case "l", "where":
+		d.printLocation()
+	case "stack", "memory", "storage":
+		d.printState(fields[0])
+	case "h", "help":
+		fmt.Fprint(d.w, help)
+	case "q", "quit":
+		return false
+	default:
+		fmt.Fprintf(d.w, "unknown command %q, type help for the list of commands\n", fields[0])
+	}
+	return true
+}
+
+const help = `s, step            next statement, entering calls
+n, next            next statement, stepping over calls
+b, back            previous statement
+o, out             first statement after the current call returns
+c, continue        next breakpoint
+rc, reverse        previous breakpoint
+g, goto <step>     step with the index
+break <file:line>  set a breakpoint
+clear <file:line>  remove a breakpoint
+breakpoints        list breakpoints
+l, where           current location
+stack              stack of the current step
+memory             memory of the current step
+storage            storage slots accessed by the call until the end of the current step
+q, quit            exit
+`
+
+func (d *debugger) printLocation() {
+	step := d.trace.Steps[d.current]
+	name, line := d.location(d.current)
+	fmt.Fprintf(d.w, "[%d/%d] %s:%d", d.current, len(d.trace.Steps)-1, name, line)
+	if step.Depth() > 0 {
+		fmt.Fprintf(d.w, " depth %d", step.Depth())
+	}
+	if opcode := d.opcodes[d.current]; opcode >= 0 {
+		fmt.Fprintf(d.w, " pc %d %s gas %d", d.structLogs[opcode].Pc, d.structLogs[opcode].Op, d.structLogs[opcode].Gas)
+	}
+	fmt.Fprintln(d.w)
+
+	lines := d.lines[step.Contract()]
+	for l := line - contextLines; l <= line+contextLines; l++ {
+		if l < 1 || l > len(lines) {
+			continue
+		}
+		marker := " "
+		if l == line {
+			marker = ">"
+		}
+		fmt.Fprintf(d.w, "%s %4d | %s\n", marker, l, lines[l-1])
+	}
+}
+
+func (d *debugger) printState(what string) {
+	if len(d.structLogs) == 0 {
+		fmt.Fprintln(d.w, "no opcode level trace loaded")
+		return
+	}
+	opcode := d.opcodes[d.current]
+	if opcode < 0 {
+		fmt.Fprintln(d.w, "step is not part of the opcode level trace")
+		return
+	}
+	log := d.structLogs[opcode]
+
+	switch what {
+	case "stack":
+		if log.Stack == nil {
+			fmt.Fprintln(d.w, "stack was not captured")
+			return
+		}
+		stack := *log.Stack
+		for i := len(stack) - 1; i >= 0; i-- {
+			fmt.Fprintf(d.w, "%4d: %s\n", len(stack)-1-i, stack[i])
+		}
+	case "memory":
+		if log.Memory == nil {
+			fmt.Fprintln(d.w, "memory was not captured")
+			return
+		}
+		for i, word := range *log.Memory {
+			fmt.Fprintf(d.w, "0x%04x: %s\n", i*32, word)
+		}
+	case "storage":
+		// storage is only captured by SLOAD and SSTORE, with all the slots the call accessed so far,
+		// so the storage of the last instruction of the step that captured it is shown
+		end := len(d.structLogs)
+		if d.current+1 < len(d.opcodes) && d.opcodes[d.current+1] > opcode {
+			end = d.opcodes[d.current+1]
+		}
+		var storage *map[string]string
+		for i := end - 1; i >= opcode && d.structLogs[i].Depth >= log.Depth && storage == nil; i-- {
+			if d.structLogs[i].Depth == log.Depth {
+				storage = d.structLogs[i].Storage
+			}
+		}
+		if storage == nil {
+			fmt.Fprintln(d.w, "no storage slots accessed by the call yet")
+			return
+		}
+		slots := []string{}
+		for slot := range *storage {
+			slots = append(slots, slot)
+		}
+		sort.Strings(slots)
+		for _, slot := range slots {
+			fmt.Fprintf(d.w, "%s: %s\n", slot, (*storage)[slot])
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tokencard/ethertest"
+)
+
+func TestDebugger(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("../../test/build/test/combined.json", "../../test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	// without gas estimation, the trace only contains the deployment and the transaction
+	opts := owner.TransactOpts(be, ethertest.WithGasLimit(1000000))
+	c, _, err := tr.DeployContract(be, opts, "test.sol:Test", "initial value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+	tx, err := c.Transact(opts, "setValue", "new value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	dir := t.TempDir()
+	tracePath := filepath.Join(dir, "trace.json")
+	structLogsPath := filepath.Join(dir, "structlogs.json")
+
+	trace := &bytes.Buffer{}
+	require.Nil(tr.SaveTrace(trace))
+	require.Nil(os.WriteFile(tracePath, trace.Bytes(), 0o644))
+	structLogs, err := be.TraceTransaction(context.Background(), tx.Hash(), nil)
+	require.Nil(err)
+	require.Nil(os.WriteFile(structLogsPath, structLogs, 0o644))
+
+	debug := func(structLogsPath string, commands ...string) string {
+		out := &bytes.Buffer{}
+		require.Nil(run(tracePath, structLogsPath, strings.NewReader(strings.Join(commands, "\n")), out))
+		return out.String()
+	}
+
+	out := debug("", "where", "quit")
+	require.True(strings.HasPrefix(out, "[0/"), out)
+	require.Contains(out, "depth 1\n")
+
+	out = debug("", "break test.sol:15", "breakpoints", "c", "stack", "s", "b", "rc", "clear test.sol:15", "c")
+	require.Contains(out, "(debug) test.sol:15\n")
+	require.Contains(out, "test.sol:15 depth 1\n")
+	require.Contains(out, ">   15 |     value = _value;\n")
+	require.Contains(out, "no opcode level trace loaded\n")
+	require.Contains(out, "end of trace\n")
+
+	// the opcode level trace is of the transaction, not of the deployment
+	last := strconv.Itoa(strings.Count(trace.String(), "],[")) // index of the last step
+	out = debug(structLogsPath, "break test.sol:15", "stack", "c", "stack", "storage", "memory", "rc", "o", "goto "+last, "storage")
+	sections := strings.Split(out, "(debug) ")
+	require.Contains(sections[0], "test.sol:10 depth 1\n")
+	require.Contains(sections[2], "step is not part of the opcode level trace\n")
+	require.Contains(sections[3], "test.sol:15 depth 1 pc ")
+	require.Contains(sections[4], "   0: 0x")
+	require.Contains(sections[5], "no storage slots accessed by the call yet\n")
+	require.Contains(sections[6], "memory was not captured\n")
+	require.Contains(sections[7], "start of trace\n")
+	require.Contains(sections[8], "end of trace\n")
+	require.Contains(sections[9], "["+last+"/"+last+"] test.sol:15 depth 1 pc ")
+	require.Contains(sections[10], "0000000000000000000000000000000000000000000000000000000000000000: 6e65772076616c7565") // "new value"
+
+	out = debug("", "goto 1000000", "unknown", "break", "help")
+	require.Contains(out, "step has to be between 0 and ")
+	require.Contains(out, `unknown command "unknown"`)
+	require.Contains(out, "usage: break <file>:<line>\n")
+	require.Contains(out, "s, step ")
+
+	require.NotNil(run(filepath.Join(dir, "missing.json"), "", strings.NewReader(""), &bytes.Buffer{}))
+}
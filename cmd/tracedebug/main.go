@@ -0,0 +1,96 @@
+// Command tracedebug is an interactive source level debugger of traces saved with TestRig.SaveTrace.
+//
+//	tracedebug -trace trace.json [-structlogs tx.json]
+//
+// The trace can be compressed with gzip.
+//
+// The optional opcode level trace of a transaction, e.g. saved from TestBackend.TraceTransaction,
+// adds the stack, memory and storage to the steps of the transaction.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
+	"github.com/tokencard/ethertest"
+)
+
+func main() {
+	tracePath := flag.String("trace", "", "trace saved with TestRig.SaveTrace")
+	structLogsPath := flag.String("structlogs", "", "opcode level trace of a transaction in debug_traceTransaction format")
+	flag.Parse()
+
+	if *tracePath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := run(*tracePath, *structLogsPath, os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(tracePath, structLogsPath string, in io.Reader, out io.Writer) error {
+	f, err := os.Open(tracePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	trace, err := ethertest.LoadTrace(f)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %s", tracePath, err.Error())
+	}
+
+	result := &logger.ExecutionResult{}
+	if structLogsPath != "" {
+		err = readJSON(structLogsPath, result)
+		if err != nil {
+			return err
+		}
+	}
+
+	d, err := newDebugger(trace, result.StructLogs, out)
+	if err != nil {
+		return err
+	}
+	d.printLocation()
+
+	scanner := bufio.NewScanner(in)
+	last := ""
+	for {
+		fmt.Fprint(out, "(debug) ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		command := scanner.Text()
+		if command == "" {
+			// repeat the last command like gdb
+			command = last
+		}
+		last = command
+		if !d.execute(command) {
+			return nil
+		}
+	}
+}
+
+func readJSON(path string, v interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	err = json.NewDecoder(f).Decode(v)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %s", path, err.Error())
+	}
+	return nil
+}
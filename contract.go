@@ -2,9 +2,12 @@ package ethertest
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/vm"
 	. "github.com/logrusorgru/aurora"
@@ -94,25 +97,59 @@ type bytecodeWithMapping struct {
 	coverages     []*sourceCodeCoverage
 	binary        []byte
 	isConstructor bool
+	// linkOffsets are offsets of addresses that are inserted into the binary
+	// when linking or deploying a library.
+	linkOffsets []int
+	// linked caches which code hashes matched the binary once linked.
+	linked map[common.Hash]bool
 }
 
-func (b *bytecodeWithMapping) executed(pc uint64, contractAddress common.Address, contract *vm.Contract) bool {
-	if contract.CodeHash != b.hash {
+func (b *bytecodeWithMapping) matches(contract *vm.Contract) bool {
+	if len(b.binary) == 0 {
 		return false
 	}
 
-	if len(b.binary) == 0 {
+	if contract.CodeHash == b.hash {
+		if b.isConstructor {
+			return b.matchesCode(contract.Code)
+		}
+		return true
+	}
+
+	if b.isConstructor || len(b.linkOffsets) == 0 {
 		return false
 	}
 
-	if b.isConstructor {
-		c := contract.Code
-		if len(c) < len(b.binary) {
-			return false
-		}
-		if bytes.Compare(contract.Code[:len(b.binary)], b.binary) != 0 {
+	matched, found := b.linked[contract.CodeHash]
+	if !found {
+		matched = b.matchesCode(contract.Code)
+		b.linked[contract.CodeHash] = matched
+	}
+	return matched
+}
+
+// matchesCode compares the code with the binary, ignoring linked addresses.
+// Constructor code can have ABI encoded arguments appended to it.
+func (b *bytecodeWithMapping) matchesCode(code []byte) bool {
+	if len(code) < len(b.binary) {
+		return false
+	}
+	if !b.isConstructor && len(code) != len(b.binary) {
+		return false
+	}
+	from := 0
+	for _, o := range b.linkOffsets {
+		if !bytes.Equal(code[from:o], b.binary[from:o]) {
 			return false
 		}
+		from = o + common.AddressLength
+	}
+	return bytes.Equal(code[from:len(b.binary)], b.binary[from:])
+}
+
+func (b *bytecodeWithMapping) executed(pc uint64, op vm.OpCode, gas uint64, contractAddress common.Address, contract *vm.Contract, depth int) bool {
+	if !b.matches(contract) {
+		return false
 	}
 
 	idx, f := b.pcToIndex[pc]
@@ -127,16 +164,23 @@ func (b *bytecodeWithMapping) executed(pc uint64, contractAddress common.Address
 			if sm.F >= 0 {
 				cov := b.coverages[sm.F]
 				cov.paintGreen(sm.S, sm.L)
-				b.tracer.executed(cov.name, string(cov.source), sm.S, sm.S+sm.L)
+				b.tracer.executed(cov.name, string(cov.source), sm.S, sm.S+sm.L, depth, pc, op, gas)
 			}
 		}
 	}
 	return true
 }
 
-func newBytecodeMapping(t *tracer, name, contractHex string, coverages []*sourceCodeCoverage, smap string, isConstructor bool) (*bytecodeWithMapping, error) {
+func newBytecodeMapping(t *tracer, name string, contractBinary []byte, refs []linkReference, coverages []*sourceCodeCoverage, smap string, isConstructor bool) (*bytecodeWithMapping, error) {
 
-	contractBinary := common.Hex2Bytes(contractHex)
+	linkOffsets := []int{}
+	if !isConstructor && isLibraryRuntime(contractBinary) {
+		linkOffsets = append(linkOffsets, 1)
+	}
+	for _, r := range refs {
+		linkOffsets = append(linkOffsets, r.offset)
+	}
+	sort.Ints(linkOffsets)
 
 	hash := common.Hash{}
 
@@ -154,12 +198,16 @@ func newBytecodeMapping(t *tracer, name, contractHex string, coverages []*source
 
 	sm, err := srcmap.Uncompress(smap)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("could not parse srcmap of %s: %s", name, err.Error())
 	}
 	skip := make([]bool, len(sm))
 
 	for i, sme := range sm {
 
+		if sme.F >= len(coverages) {
+			return nil, fmt.Errorf("srcmap entry %d of %s references source %d, %d sources are known", i, name, sme.F, len(coverages))
+		}
+
 		if sme.F >= 0 {
 			cov := coverages[sme.F]
 			ast := cov.ast.Ast
@@ -201,10 +249,12 @@ func newBytecodeMapping(t *tracer, name, contractHex string, coverages []*source
 		skipCoverage:  skip,
 		coverages:     coverages,
 		isConstructor: isConstructor,
+		linkOffsets:   linkOffsets,
+		linked:        map[common.Hash]bool{},
 	}, nil
 }
 
-func newContract(name string, t *tracer, source []byte, ss solcSource, con *solcContract, coverages []*sourceCodeCoverage) (*contract, error) {
+func newContract(name string, t *tracer, source []byte, ss solcSource, con *solcContract, coverages []*sourceCodeCoverage, libraries []string) (*contract, error) {
 	functions := map[[4]byte]*Function{}
 
 	var err error
@@ -251,31 +301,60 @@ func newContract(name string, t *tracer, source []byte, ss solcSource, con *solc
 
 	// sourceCodeCoverage := newSourceCodeCoverage(name, source, sourceIndex)
 
-	runtimeMapping, err := newBytecodeMapping(t, name, con.BinRuntime, coverages, con.SrcmapRuntime, false)
+	runtimeBinary, runtimeRefs, err := parseBytecode(con.BinRuntime, libraries)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse runtime bytecode of %s: %s", name, err.Error())
+	}
+
+	runtimeMapping, err := newBytecodeMapping(t, name, runtimeBinary, runtimeRefs, coverages, con.SrcmapRuntime, false)
 	if err != nil {
 		return nil, err
 	}
 
-	constructorMapping, err := newBytecodeMapping(t, name, con.Bin, coverages, con.Srcmap, true)
+	constructorBinary, constructorRefs, err := parseBytecode(con.Bin, libraries)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse bytecode of %s: %s", name, err.Error())
+	}
+
+	constructorMapping, err := newBytecodeMapping(t, name, constructorBinary, constructorRefs, coverages, con.Srcmap, true)
 	if err != nil {
 		return nil, err
 	}
 
+	contractABI, err := con.parseABI()
+	if err != nil {
+		return nil, fmt.Errorf("could not parse ABI of %s: %s", name, err.Error())
+	}
+
+	storage, err := con.parseStorageLayout()
+	if err != nil {
+		return nil, fmt.Errorf("could not parse storage layout of %s: %s", name, err.Error())
+	}
+
 	return &contract{
-		name:      name,
-		coverages: coverages,
-		mappings:  []*bytecodeWithMapping{runtimeMapping, constructorMapping},
-		functions: functions,
-		addresses: map[common.Address]struct{}{},
+		name:           name,
+		abi:            contractABI,
+		storage:        storage,
+		coverages:      coverages,
+		mappings:       []*bytecodeWithMapping{runtimeMapping, constructorMapping},
+		functions:      functions,
+		addresses:      map[common.Address]struct{}{},
+		bytecode:       constructorBinary,
+		linkReferences: constructorRefs,
 	}, nil
 }
 
 type contract struct {
 	name      string
+	abi       *abi.ABI
+	storage   *storageLayout
 	coverages []*sourceCodeCoverage
 	mappings  []*bytecodeWithMapping
 	functions map[[4]byte]*Function
 	addresses map[common.Address]struct{}
+	// bytecode is the unlinked deployment bytecode of the contract.
+	bytecode       []byte
+	linkReferences []linkReference
 }
 
 type Function struct {
@@ -312,10 +391,10 @@ func (c *contract) transactionCommited(to common.Address, data []byte, gasUsed u
 
 }
 
-func (c *contract) executed(pc uint64, contractAddress common.Address, contract *vm.Contract) {
+func (c *contract) executed(pc uint64, op vm.OpCode, gas uint64, contractAddress common.Address, contract *vm.Contract, depth int) {
 
 	for _, m := range c.mappings {
-		matched := m.executed(pc, contractAddress, contract)
+		matched := m.executed(pc, op, gas, contractAddress, contract, depth)
 		if matched {
 			c.addresses[contractAddress] = struct{}{}
 		}
@@ -341,11 +420,35 @@ func (s solcCombined) findSourceIndex(name string) int {
 }
 
 type solcContract struct {
-	BinRuntime    string  `json:"bin-runtime"`
-	SrcmapRuntime string  `json:"srcmap-runtime"`
-	Bin           string  `json:"bin"`
-	Srcmap        string  `json:"srcmap"`
-	Asm           solcAsm `json:"asm"`
+	ABI           json.RawMessage `json:"abi"`
+	BinRuntime    string          `json:"bin-runtime"`
+	SrcmapRuntime string          `json:"srcmap-runtime"`
+	Bin           string          `json:"bin"`
+	Srcmap        string          `json:"srcmap"`
+	StorageLayout json.RawMessage `json:"storage-layout"`
+	Asm           solcAsm         `json:"asm"`
+}
+
+// parseABI parses the ABI of the contract if it was included in the combined-json.
+// Older versions of solc encode the ABI as a JSON string.
+func (s *solcContract) parseABI() (*abi.ABI, error) {
+	if len(s.ABI) == 0 {
+		return nil, nil
+	}
+
+	raw := string(s.ABI)
+	if strings.HasPrefix(raw, `"`) {
+		err := json.Unmarshal(s.ABI, &raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
 }
 
 type solcAsm struct {
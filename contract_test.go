@@ -22,7 +22,7 @@ func TestContract(t *testing.T) {
 
 	require := require.New(t)
 	be := tr.NewTestBackend(ethertest.WithBlockGasLimit(8000000), ethertest.WithBlockchainTime(time.Now().Add(-24*time.Hour)))
-	_, tx, testBinding, err := bindings.DeployTest(owner.TransactOpts(), be, "initial value")
+	_, tx, testBinding, err := bindings.DeployTest(owner.TransactOpts(be), be, "initial value")
 	require.Nil(err)
 	be.Commit()
 
@@ -30,7 +30,7 @@ func TestContract(t *testing.T) {
 	require.Nil(err)
 	require.Equal(types.ReceiptStatusSuccessful, receipt.Status)
 
-	tx, err = testBinding.SetValue(owner.TransactOpts(), "new value")
+	tx, err = testBinding.SetValue(owner.TransactOpts(be), "new value")
 	require.Nil(err)
 	be.Commit()
 
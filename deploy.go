@@ -0,0 +1,136 @@
+package ethertest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DeployedContract is a handle to a contract on a TestBackend.
+// It calls and transacts with the contract using the ABI from the combined-json,
+// so no generated bindings are needed.
+type DeployedContract struct {
+	name    string
+	address common.Address
+	abi     abi.ABI
+	be      TestBackend
+	bound   *bind.BoundContract
+}
+
+// Name returns the fully qualified name of the contract.
+func (d *DeployedContract) Name() string {
+	return d.name
+}
+
+// Address returns the address of the contract.
+func (d *DeployedContract) Address() common.Address {
+	return d.address
+}
+
+// ABI returns the ABI of the contract.
+func (d *DeployedContract) ABI() abi.ABI {
+	return d.abi
+}
+
+// Call executes a (constant) method of the contract on the latest block
+// and returns the unpacked return values.
+func (d *DeployedContract) Call(method string, args ...interface{}) ([]interface{}, error) {
+	return d.CallAt(nil, method, args...)
+}
+
+// CallAt executes a (constant) method of the contract on the state of the given block
+// and returns the unpacked return values.
+func (d *DeployedContract) CallAt(blockNumber *big.Int, method string, args ...interface{}) ([]interface{}, error) {
+	m, found := d.abi.Methods[method]
+	if !found {
+		return nil, fmt.Errorf("contract %s has no method %q", d.name, method)
+	}
+
+	input, err := d.abi.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	output, err := d.be.CallContract(ctx, ethereum.CallMsg{To: &d.address, Data: input}, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(output) == 0 && len(m.Outputs) > 0 {
+		code, err := d.be.CodeAt(ctx, d.address, blockNumber)
+		if err != nil {
+			return nil, err
+		}
+		if len(code) == 0 {
+			return nil, bind.ErrNoCode
+		}
+	}
+
+	return m.Outputs.UnpackValues(output)
+}
+
+// Transact sends a transaction calling a method of the contract.
+func (d *DeployedContract) Transact(opts *bind.TransactOpts, method string, args ...interface{}) (*types.Transaction, error) {
+	return d.bound.Transact(opts, method, args...)
+}
+
+// DeployContract deploys the contract with the fully qualified name (e.g. "test.sol:Test")
+// on the backend, passing args to the constructor.
+// Bytecode is linked to the libraries on the backend, deploying missing libraries first.
+// Contract's ABI has to be included in the combined-json (`--combined-json abi,...`).
+func (t *TestRig) DeployContract(be TestBackend, opts *bind.TransactOpts, name string, args ...interface{}) (*DeployedContract, *types.Transaction, error) {
+	c, err := t.contract(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c.abi == nil {
+		return nil, nil, fmt.Errorf("combined-json does not contain ABI of %s", name)
+	}
+
+	code, err := t.LinkBytecode(be, opts, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	address, tx, bound, err := bind.DeployContract(opts, *c.abi, code, be, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &DeployedContract{
+		name:    name,
+		address: address,
+		abi:     *c.abi,
+		be:      be,
+		bound:   bound,
+	}, tx, nil
+}
+
+// ContractAt returns a handle to the contract with the fully qualified name
+// that is already deployed on the backend at the address.
+func (t *TestRig) ContractAt(be TestBackend, name string, address common.Address) (*DeployedContract, error) {
+	c, err := t.contract(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.abi == nil {
+		return nil, fmt.Errorf("combined-json does not contain ABI of %s", name)
+	}
+
+	return &DeployedContract{
+		name:    name,
+		address: address,
+		abi:     *c.abi,
+		be:      be,
+		bound:   bind.NewBoundContract(address, *c.abi, be, be, be),
+	}, nil
+}
@@ -0,0 +1,108 @@
+package ethertest_test
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tokencard/ethertest"
+)
+
+func TestDeployContract(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	c, tx, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+	be.Commit()
+
+	successful, err := ethertest.IsSuccessful(be, tx)
+	require.Nil(err)
+	require.True(successful)
+
+	value, err := c.Call("value")
+	require.Nil(err)
+	require.Equal([]interface{}{"initial value"}, value)
+
+	tx, err = c.Transact(owner.TransactOpts(be), "setValue", "new value")
+	require.Nil(err)
+	be.Commit()
+
+	successful, err = ethertest.IsSuccessful(be, tx)
+	require.Nil(err)
+	require.True(successful)
+
+	bound, err := tr.ContractAt(be, "test.sol:Test", c.Address())
+	require.Nil(err)
+
+	value, err = bound.Call("value")
+	require.Nil(err)
+	require.Equal([]interface{}{"new value"}, value)
+
+	_, err = c.Call("noSuchMethod")
+	require.NotNil(err)
+
+	_, _, err = tr.DeployContract(be, owner.TransactOpts(be), "test.sol:NoSuchContract")
+	require.NotNil(err)
+}
+
+func TestDeployLinkedContract(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/linked/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	require.Zero(tr.CoverageOf("lib/math.sol"))
+
+	library, err := tr.DeployLibrary(be, owner.TransactOpts(be), "lib/math.sol:Math")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	c, _, err := tr.DeployContract(be, owner.TransactOpts(be), "linked.sol:Linked")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	code, err := be.CodeAt(context.Background(), c.Address(), nil)
+	require.Nil(err)
+	require.True(bytes.Contains(code, library.Bytes()))
+
+	_, err = c.Transact(owner.TransactOpts(be), "add", big.NewInt(5))
+	require.Nil(err)
+	require.Nil(be.Commit())
+	require.Contains(tr.LastExecuted(), "linked.sol:10\n")
+
+	total, err := c.Call("total")
+	require.Nil(err)
+	require.Equal([]interface{}{big.NewInt(5)}, total)
+
+	require.Equal(100.0, tr.CoverageOf("lib/math.sol"))
+	require.Greater(tr.CoverageOf("linked.sol"), 0.0)
+
+	saved := &bytes.Buffer{}
+	require.Nil(tr.SaveTrace(saved))
+	trace, err := ethertest.LoadTrace(saved)
+	require.Nil(err)
+
+	inLibrary := 0
+	for _, s := range trace.Steps {
+		if trace.Contracts[s.Contract()].Name == "lib/math.sol" {
+			require.Equal(2, s.Depth())
+			inLibrary++
+		}
+	}
+	require.NotZero(inLibrary)
+}
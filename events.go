@@ -0,0 +1,433 @@
+package ethertest
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Event is a log decoded with the ABI of a registered contract.
+type Event struct {
+	Name    string
+	Address common.Address
+	Inputs  abi.Arguments
+	// Values are in the order of the inputs. Indexed strings, bytes and arrays are only stored as their hash.
+	Values []interface{}
+	Log    *types.Log
+}
+
+// Arg returns the value of the named event argument, or nil if the event has no such argument.
+func (e *Event) Arg(name string) interface{} {
+	for i, input := range e.Inputs {
+		if input.Name == name {
+			return e.Values[i]
+		}
+	}
+	return nil
+}
+
+func (e *Event) String() string {
+	if e.Name == "" {
+		if len(e.Log.Topics) == 0 {
+			return fmt.Sprintf("anonymous log by %s", e.Address.Hex())
+		}
+		return fmt.Sprintf("unknown event %s by %s", e.Log.Topics[0].Hex(), e.Address.Hex())
+	}
+	return fmt.Sprintf("%s(%s) by %s", e.Name, formatArguments(e.Inputs, e.Values), e.Address.Hex())
+}
+
+// ExpectedEvent describes an event that should be emitted by a transaction.
+type ExpectedEvent struct {
+	name    string
+	args    []interface{}
+	address *common.Address
+}
+
+// Emitted creates an ExpectedEvent with the name and the values of all event arguments.
+// If no values are given, only the name of the event is matched.
+// Integer values match integer arguments of any size and indexed strings and bytes match their hash.
+func Emitted(name string, args ...interface{}) ExpectedEvent {
+	return ExpectedEvent{name: name, args: args}
+}
+
+// By restricts the ExpectedEvent to events emitted by the contract at the address.
+func (e ExpectedEvent) By(address common.Address) ExpectedEvent {
+	e.address = &address
+	return e
+}
+
+func (e ExpectedEvent) String() string {
+	s := e.name
+	if len(e.args) > 0 {
+		args := make([]string, len(e.args))
+		for i, v := range e.args {
+			args[i] = formatValue(v)
+		}
+		s = fmt.Sprintf("%s(%s)", s, strings.Join(args, ", "))
+	}
+	if e.address != nil {
+		s = fmt.Sprintf("%s by %s", s, e.address.Hex())
+	}
+	return s
+}
+
+func (e ExpectedEvent) matches(event *Event) bool {
+	if event.Name != e.name {
+		return false
+	}
+	if e.address != nil && *e.address != event.Address {
+		return false
+	}
+	if len(e.args) == 0 {
+		return true
+	}
+	if len(e.args) != len(event.Values) {
+		return false
+	}
+	for i, v := range e.args {
+		if !valuesEqual(v, event.Values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// AddABI registers the ABI of a contract that is not added with AddCoverageForContracts,
+// e.g. the ABI of abigen bindings, so its events, errors and calls can be decoded.
+func (t *TestRig) AddABI(contractABI abi.ABI) *TestRig {
+	t.abis = append(t.abis, contractABI)
+	return t
+}
+
+// DecodeEvents decodes the logs with the ABIs of the registered contracts.
+// Logs that don't match any registered event are returned as events without a name.
+func (t *TestRig) DecodeEvents(logs []*types.Log) ([]*Event, error) {
+	abis := t.registeredABIs()
+
+	events := make([]*Event, len(logs))
+	for i, log := range logs {
+		event := &Event{Address: log.Address, Log: log}
+		if ev := findEvent(abis, log); ev != nil {
+			var err error
+			event, err = decodeEvent(ev, log)
+			if err != nil {
+				return nil, fmt.Errorf("could not decode log %d as %s: %v", log.Index, ev.Sig, err)
+			}
+		}
+		events[i] = event
+	}
+	return events, nil
+}
+
+// Events returns the decoded events emitted by the mined transaction.
+func (t *TestRig) Events(be TestBackend, tx *types.Transaction) ([]*Event, error) {
+	receipt, err := be.TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if receipt == nil {
+		return nil, ErrTransactionPending
+	}
+	return t.DecodeEvents(receipt.Logs)
+}
+
+// ExpectEvent returns an error listing all emitted events
+// if the transaction didn't emit the event with the given argument values.
+// Events with the same name but a different number of arguments, e.g. of another contract, are skipped.
+// If no event matches and none of the events with the name has as many arguments as values are given,
+// the number of arguments is reported instead.
+func (t *TestRig) ExpectEvent(be TestBackend, tx *types.Transaction, name string, args ...interface{}) error {
+	events, err := t.Events(be, tx)
+	if err != nil {
+		return err
+	}
+
+	expected := Emitted(name, args...)
+	var arityErr error
+	sameArity := false
+	for _, e := range events {
+		if expected.matches(e) {
+			return nil
+		}
+		if e.Name != name || len(args) == 0 {
+			continue
+		}
+		if len(args) == len(e.Values) {
+			sameArity = true
+		} else if arityErr == nil {
+			arityErr = fmt.Errorf("event %s has %d arguments, got %d values", name, len(e.Values), len(args))
+		}
+	}
+	if arityErr != nil && !sameArity {
+		return arityErr
+	}
+	return fmt.Errorf("event %s was not emitted by transaction %s, emitted events:\n%s", expected, tx.Hash().Hex(), formatEvents(events))
+}
+
+// ExpectNoEvent returns an error if the transaction emitted the event.
+// If argument values are given, only events with these values are considered.
+func (t *TestRig) ExpectNoEvent(be TestBackend, tx *types.Transaction, name string, args ...interface{}) error {
+	events, err := t.Events(be, tx)
+	if err != nil {
+		return err
+	}
+
+	expected := Emitted(name, args...)
+	for _, e := range events {
+		if expected.matches(e) {
+			return fmt.Errorf("event %s was emitted by transaction %s: %s", expected, tx.Hash().Hex(), e)
+		}
+	}
+	return nil
+}
+
+// ExpectEvents returns an error with a diff of the events if the transaction
+// didn't emit exactly the expected events in the given order.
+func (t *TestRig) ExpectEvents(be TestBackend, tx *types.Transaction, expected ...ExpectedEvent) error {
+	events, err := t.Events(be, tx)
+	if err != nil {
+		return err
+	}
+
+	n := len(events)
+	if len(expected) > n {
+		n = len(expected)
+	}
+
+	matching := true
+	diff := &strings.Builder{}
+	for i := 0; i < n; i++ {
+		if i < len(expected) && i < len(events) && expected[i].matches(events[i]) {
+			fmt.Fprintf(diff, "  %d: %s\n", i, events[i])
+			continue
+		}
+		matching = false
+		if i < len(expected) {
+			fmt.Fprintf(diff, "- %d: %s\n", i, expected[i])
+		}
+		if i < len(events) {
+			fmt.Fprintf(diff, "+ %d: %s\n", i, events[i])
+		}
+	}
+	if matching {
+		return nil
+	}
+	return fmt.Errorf("events emitted by transaction %s don't match (- expected, + emitted):\n%s", tx.Hash().Hex(), diff)
+}
+
+// ExpectEventsUnordered returns an error with a diff of the events if the transaction
+// didn't emit exactly the expected events, in any order.
+func (t *TestRig) ExpectEventsUnordered(be TestBackend, tx *types.Transaction, expected ...ExpectedEvent) error {
+	events, err := t.Events(be, tx)
+	if err != nil {
+		return err
+	}
+
+	matched := make([]bool, len(events))
+	missing := []ExpectedEvent{}
+	for _, e := range expected {
+		found := false
+		for i, event := range events {
+			if !matched[i] && e.matches(event) {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, e)
+		}
+	}
+
+	matching := len(missing) == 0
+	diff := &strings.Builder{}
+	for i, event := range events {
+		if matched[i] {
+			fmt.Fprintf(diff, "  %d: %s\n", i, event)
+		}
+	}
+	for _, e := range missing {
+		fmt.Fprintf(diff, "-    %s\n", e)
+	}
+	for i, event := range events {
+		if !matched[i] {
+			matching = false
+			fmt.Fprintf(diff, "+ %d: %s\n", i, event)
+		}
+	}
+	if matching {
+		return nil
+	}
+	return fmt.Errorf("events emitted by transaction %s don't match (- expected, + emitted):\n%s", tx.Hash().Hex(), diff)
+}
+
+// registeredABIs returns the ABIs of all registered contracts.
+func (t *TestRig) registeredABIs() []abi.ABI {
+	names := []string{}
+	for n, c := range t.contracts {
+		if c.abi != nil {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	abis := []abi.ABI{}
+	for _, n := range names {
+		abis = append(abis, *t.contracts[n].abi)
+	}
+	return append(abis, t.abis...)
+}
+
+// findEvent returns the event of the ABIs matching the signature and the number of topics of the log.
+// The number of topics tells apart events with the same signature but different indexed arguments,
+// e.g. ERC-20 and ERC-721 Transfer events.
+func findEvent(abis []abi.ABI, log *types.Log) *abi.Event {
+	if len(log.Topics) == 0 {
+		return nil
+	}
+	for _, a := range abis {
+		for _, ev := range a.Events {
+			if ev.Anonymous || ev.ID != log.Topics[0] {
+				continue
+			}
+			indexed := 0
+			for _, input := range ev.Inputs {
+				if input.Indexed {
+					indexed++
+				}
+			}
+			if indexed == len(log.Topics)-1 {
+				ev := ev
+				return &ev
+			}
+		}
+	}
+	return nil
+}
+
+func decodeEvent(ev *abi.Event, log *types.Log) (*Event, error) {
+	inputs := make(abi.Arguments, len(ev.Inputs))
+	copy(inputs, ev.Inputs)
+	for i := range inputs {
+		if inputs[i].Name == "" {
+			inputs[i].Name = fmt.Sprintf("arg%d", i)
+		}
+	}
+
+	nonIndexed, err := inputs.Unpack(log.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	indexedInputs := abi.Arguments{}
+	for _, input := range inputs {
+		if input.Indexed {
+			indexedInputs = append(indexedInputs, input)
+		}
+	}
+	indexed := map[string]interface{}{}
+	if err := abi.ParseTopicsIntoMap(indexed, indexedInputs, log.Topics[1:]); err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(inputs))
+	for i, input := range inputs {
+		if input.Indexed {
+			values[i] = indexed[input.Name]
+		} else {
+			values[i], nonIndexed = nonIndexed[0], nonIndexed[1:]
+		}
+	}
+
+	return &Event{
+		Name:    ev.Name,
+		Address: log.Address,
+		Inputs:  inputs,
+		Values:  values,
+		Log:     log,
+	}, nil
+}
+
+// valuesEqual compares an expected value with a decoded event argument.
+func valuesEqual(expected, actual interface{}) bool {
+	if e, ok := toBigInt(expected); ok {
+		a, ok := toBigInt(actual)
+		return ok && e.Cmp(a) == 0
+	}
+	// indexed dynamic values are stored as their hash
+	if h, ok := actual.(common.Hash); ok {
+		switch e := expected.(type) {
+		case string:
+			return crypto.Keccak256Hash([]byte(e)) == h
+		case []byte:
+			return crypto.Keccak256Hash(e) == h
+		}
+	}
+	return reflect.DeepEqual(expected, actual)
+}
+
+func toBigInt(v interface{}) (*big.Int, bool) {
+	if b, ok := v.(*big.Int); ok {
+		return b, b != nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(rv.Uint()), true
+	}
+	return nil, false
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case []byte:
+		return hexutil.Encode(v)
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case *big.Int:
+		return v.String()
+	case string:
+		return fmt.Sprintf("%q", v)
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		b := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(b), rv)
+		return hexutil.Encode(b)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// formatArguments formats the values of the arguments, prefixed by the names of named arguments.
+func formatArguments(arguments abi.Arguments, values []interface{}) string {
+	args := make([]string, len(values))
+	for i, v := range values {
+		args[i] = formatValue(v)
+		if i < len(arguments) && arguments[i].Name != "" {
+			args[i] = fmt.Sprintf("%s: %s", arguments[i].Name, args[i])
+		}
+	}
+	return strings.Join(args, ", ")
+}
+
+func formatEvents(events []*Event) string {
+	if len(events) == 0 {
+		return "  none\n"
+	}
+	s := &strings.Builder{}
+	for i, e := range events {
+		fmt.Fprintf(s, "  %d: %s\n", i, e)
+	}
+	return s.String()
+}
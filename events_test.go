@@ -0,0 +1,166 @@
+package ethertest_test
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+	"github.com/tokencard/ethertest"
+)
+
+const erc20EventsABI = `[
+	{"type":"event","name":"Approval","inputs":[{"name":"owner","type":"address","indexed":true},{"name":"spender","type":"address","indexed":true},{"name":"value","type":"uint256"}]},
+	{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256"}]}
+]`
+
+// emitterCode emits Approval(caller, to, value) and Transfer(caller, to, value)
+// where to and value are the first two words of the call data.
+func emitterCode() []byte {
+	emit := func(sig string) []byte {
+		code := []byte{0x60, 0x00, 0x35, 0x33, 0x7f} // to, caller, topic
+		code = append(code, crypto.Keccak256([]byte(sig))...)
+		return append(code, 0x60, 0x20, 0x60, 0x00, 0xa3) // LOG3(0, 32, ...)
+	}
+	code := []byte{0x60, 0x20, 0x35, 0x60, 0x00, 0x52} // mstore(0, value)
+	code = append(code, emit("Approval(address,address,uint256)")...)
+	code = append(code, emit("Transfer(address,address,uint256)")...)
+	return append(code, 0x00)
+}
+
+// transferEmitterCode emits Transfer(caller, to, value) of erc20EventsABI followed by Transfer(to, value)
+// of transferToEventABI, where to and value are the first two words of the call data.
+func transferEmitterCode() []byte {
+	code := []byte{0x60, 0x20, 0x35, 0x60, 0x00, 0x52} // mstore(0, value)
+	code = append(code, 0x60, 0x00, 0x35, 0x33, 0x7f)  // to, caller, topic
+	code = append(code, crypto.Keccak256([]byte("Transfer(address,address,uint256)"))...)
+	code = append(code, 0x60, 0x20, 0x60, 0x00, 0xa3) // LOG3(0, 32, ...)
+	code = append(code, 0x60, 0x00, 0x35, 0x7f)       // to, topic
+	code = append(code, crypto.Keccak256([]byte("Transfer(address,uint256)"))...)
+	return append(code, 0x60, 0x20, 0x60, 0x00, 0xa2, 0x00) // LOG2(0, 32, ...)
+}
+
+const transferToEventABI = `[
+	{"type":"event","name":"Transfer","inputs":[{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256"}]}
+]`
+
+func TestExpectEventWithDifferentArguments(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+	var emitter = common.HexToAddress("0xe2")
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	for _, definition := range []string{erc20EventsABI, transferToEventABI} {
+		parsed, err := abi.JSON(strings.NewReader(definition))
+		require.Nil(t, err)
+		tr.AddABI(parsed)
+	}
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	require.Nil(be.SetCode(emitter, transferEmitterCode()))
+	require.Nil(be.Commit())
+
+	data := append(common.LeftPadBytes(receiver.Address().Bytes(), 32), common.LeftPadBytes(big.NewInt(100).Bytes(), 32)...)
+	tx, err := bind.NewBoundContract(emitter, abi.ABI{}, be, be, be).RawTransact(owner.TransactOpts(be), data)
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	// the first Transfer event has 3 arguments and is skipped
+	require.Nil(tr.ExpectEvent(be, tx, "Transfer", receiver.Address(), 100))
+	require.Nil(tr.ExpectEvent(be, tx, "Transfer", owner.Address(), receiver.Address(), 100))
+
+	err = tr.ExpectEvent(be, tx, "Transfer", receiver.Address(), 99)
+	require.NotNil(err)
+	require.Contains(err.Error(), "was not emitted")
+	require.EqualError(tr.ExpectEvent(be, tx, "Transfer", receiver.Address()), "event Transfer has 3 arguments, got 1 values")
+}
+
+func TestEvents(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+	var emitter = common.HexToAddress("0xe1")
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	require.Nil(be.SetCode(emitter, emitterCode()))
+	require.Nil(be.Commit())
+
+	data := append(common.LeftPadBytes(receiver.Address().Bytes(), 32), common.LeftPadBytes(big.NewInt(100).Bytes(), 32)...)
+	tx, err := bind.NewBoundContract(emitter, abi.ABI{}, be, be, be).RawTransact(owner.TransactOpts(be), data)
+	require.Nil(err)
+	_, err = tr.Events(be, tx)
+	require.True(errors.Is(err, ethertest.ErrTransactionPending))
+	require.Nil(be.Commit())
+
+	// logs without a registered ABI can't be decoded
+	events, err := tr.Events(be, tx)
+	require.Nil(err)
+	require.Len(events, 2)
+	require.Equal("", events[0].Name)
+	require.NotNil(tr.ExpectEvent(be, tx, "Transfer"))
+
+	parsed, err := abi.JSON(strings.NewReader(erc20EventsABI))
+	require.Nil(err)
+	tr.AddABI(parsed)
+
+	events, err = tr.Events(be, tx)
+	require.Nil(err)
+	require.Len(events, 2)
+	require.Equal("Transfer", events[1].Name)
+	require.Equal(emitter, events[1].Address)
+	require.Equal(owner.Address(), events[1].Arg("from"))
+	require.Equal(big.NewInt(100), events[1].Arg("value"))
+
+	require.Nil(tr.ExpectEvent(be, tx, "Transfer"))
+	require.Nil(tr.ExpectEvent(be, tx, "Transfer", owner.Address(), receiver.Address(), big.NewInt(100)))
+	require.Nil(tr.ExpectEvent(be, tx, "Transfer", owner.Address(), receiver.Address(), 100))
+	err = tr.ExpectEvent(be, tx, "Transfer", owner.Address(), receiver.Address(), 99)
+	require.NotNil(err)
+	require.Contains(err.Error(), "1: Transfer(from: "+owner.Address().Hex())
+	require.NotNil(tr.ExpectEvent(be, tx, "Transfer", owner.Address()))
+
+	require.Nil(tr.ExpectNoEvent(be, tx, "Deposit"))
+	require.Nil(tr.ExpectNoEvent(be, tx, "Transfer", owner.Address(), receiver.Address(), 99))
+	require.NotNil(tr.ExpectNoEvent(be, tx, "Transfer"))
+
+	require.Nil(tr.ExpectEvents(be, tx,
+		ethertest.Emitted("Approval", owner.Address(), receiver.Address(), 100),
+		ethertest.Emitted("Transfer", owner.Address(), receiver.Address(), 100).By(emitter),
+	))
+	err = tr.ExpectEvents(be, tx,
+		ethertest.Emitted("Transfer"),
+		ethertest.Emitted("Approval"),
+	)
+	require.NotNil(err)
+	require.Contains(err.Error(), "- 0: Transfer\n+ 0: Approval(owner: ")
+	require.NotNil(tr.ExpectEvents(be, tx, ethertest.Emitted("Approval")))
+	require.NotNil(tr.ExpectEvents(be, tx,
+		ethertest.Emitted("Approval"),
+		ethertest.Emitted("Transfer").By(receiver.Address()),
+	))
+
+	require.Nil(tr.ExpectEventsUnordered(be, tx,
+		ethertest.Emitted("Transfer"),
+		ethertest.Emitted("Approval"),
+	))
+	err = tr.ExpectEventsUnordered(be, tx,
+		ethertest.Emitted("Transfer"),
+		ethertest.Emitted("Transfer"),
+	)
+	require.NotNil(err)
+	require.Contains(err.Error(), "-    Transfer\n+ 0: Approval(")
+}
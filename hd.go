@@ -0,0 +1,97 @@
+package ethertest
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// DefaultMnemonic is the mnemonic of the accounts handed out by TestRig.Accounts.
+// It is the same mnemonic Hardhat and Anvil use, so the accounts have well known addresses.
+const DefaultMnemonic = "test test test test test test test test test test test junk"
+
+// DefaultDerivationPath is the BIP-44 derivation path of the first Ethereum account of a mnemonic.
+const DefaultDerivationPath = "m/44'/60'/0'/0/0"
+
+// NewAccountFromSeed creates an account with a private key derived from the seed,
+// so the same seed always results in the same address.
+func NewAccountFromSeed(seed string) *Account {
+	k, err := crypto.ToECDSA(crypto.Keccak256([]byte(seed)))
+
+	// will only happen if the hash of the seed is not a valid private key
+	if err != nil {
+		panic(err)
+	}
+
+	return &Account{k}
+}
+
+// NewAccountFromMnemonic creates an account with a private key derived from the BIP-39 mnemonic
+// using the BIP-32 derivation path (e.g. DefaultDerivationPath).
+func NewAccountFromMnemonic(mnemonic, path string) (*Account, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %v", err)
+	}
+
+	derivationPath, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := deriveKey(seed, derivationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Account{k}, nil
+}
+
+// deriveKey derives the private key of the BIP-32 path from the seed.
+func deriveKey(seed []byte, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode := sum[:32], sum[32:]
+
+	n := crypto.S256().Params().N
+	for _, index := range path {
+		var data []byte
+		if index >= 0x80000000 {
+			data = append([]byte{0}, key...)
+		} else {
+			k, err := crypto.ToECDSA(key)
+			if err != nil {
+				return nil, err
+			}
+			data = crypto.CompressPubkey(&k.PublicKey)
+		}
+		data = binary.BigEndian.AppendUint32(data, index)
+
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+
+		child := new(big.Int).SetBytes(sum[:32])
+		if child.Cmp(n) >= 0 {
+			return nil, fmt.Errorf("invalid child key at index %d", index)
+		}
+		child.Add(child, new(big.Int).SetBytes(key))
+		child.Mod(child, n)
+		if child.Sign() == 0 {
+			return nil, fmt.Errorf("invalid child key at index %d", index)
+		}
+
+		key, chainCode = math.PaddedBigBytes(child, 32), sum[32:]
+	}
+
+	return crypto.ToECDSA(key)
+}
@@ -0,0 +1,54 @@
+package ethertest_test
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+	"github.com/tokencard/ethertest"
+)
+
+func TestNewAccountFromMnemonic(t *testing.T) {
+	require := require.New(t)
+
+	a, err := ethertest.NewAccountFromMnemonic(ethertest.DefaultMnemonic, ethertest.DefaultDerivationPath)
+	require.Nil(err)
+	require.Equal(common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"), a.Address())
+
+	a, err = ethertest.NewAccountFromMnemonic(ethertest.DefaultMnemonic, "m/44'/60'/0'/0/1")
+	require.Nil(err)
+	require.Equal(common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"), a.Address())
+
+	_, err = ethertest.NewAccountFromMnemonic("test test test", ethertest.DefaultDerivationPath)
+	require.NotNil(err)
+
+	_, err = ethertest.NewAccountFromMnemonic(ethertest.DefaultMnemonic, "m/not/a/path")
+	require.NotNil(err)
+}
+
+func TestNewAccountFromSeed(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(ethertest.NewAccountFromSeed("owner").Address(), ethertest.NewAccountFromSeed("owner").Address())
+	require.NotEqual(ethertest.NewAccountFromSeed("owner").Address(), ethertest.NewAccountFromSeed("other").Address())
+}
+
+func TestRigAccounts(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+
+	require := require.New(t)
+	accounts := tr.Accounts(3, ethertest.EthToWei(10))
+	require.Len(accounts, 3)
+	require.Equal(common.HexToAddress("0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC"), accounts[2].Address())
+
+	owner := tr.NamedAccount("owner", ethertest.EthToWei(5))
+	require.Equal(ethertest.NewAccountFromSeed("owner").Address(), owner.Address())
+
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	for _, a := range accounts {
+		require.Equal(ethertest.EthToWei(10), a.Balance(be))
+	}
+	require.Equal(ethertest.EthToWei(5), owner.Balance(be))
+}
@@ -0,0 +1,31 @@
+package ethertest
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/tokencard/ethertest/backends"
+)
+
+// ImpersonatedTransactOpts creates TransactOpts sending transactions from the address without its private key.
+// The backend accepts the transactions only while the address is impersonated with TestBackend.Impersonate.
+// Impersonated addresses with code can send transactions as well, they aren't checked to be externally owned accounts (EIP-3607).
+func ImpersonatedTransactOpts(be TestBackend, address common.Address, modifiers ...TransactionOptionModifier) *bind.TransactOpts {
+	signer := types.LatestSigner(be.Blockchain().Config())
+	to := &bind.TransactOpts{
+		From: address,
+		Signer: func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if from != address {
+				return nil, bind.ErrNotAuthorized
+			}
+			return backends.SignImpersonated(tx, signer, address)
+		},
+		Context: context.Background(),
+	}
+	for _, m := range modifiers {
+		m(to)
+	}
+	return to
+}
@@ -0,0 +1,83 @@
+package ethertest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+	"github.com/tokencard/ethertest"
+)
+
+func TestImpersonation(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var whale = common.HexToAddress("0x00000000219ab540356cBB839Cbe05303d7705Fa")
+	var receiver = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(whale, ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	opts := ethertest.ImpersonatedTransactOpts(be, whale)
+	transfer := func() error {
+		opts.Value, opts.GasLimit = ethertest.EthToWei(1), 21000
+		defer func() { opts.Value, opts.GasLimit = nil, 0 }()
+		_, err := bind.NewBoundContract(receiver.Address(), abi.ABI{}, be, be, be).Transfer(opts)
+		return err
+	}
+
+	require.True(errors.Is(transfer(), ethertest.ErrInvalidSender))
+
+	be.Impersonate(whale)
+	require.Nil(transfer())
+	require.Nil(be.Commit())
+	require.Equal(ethertest.EthToWei(1), receiver.Balance(be))
+
+	c, tx, err := tr.DeployContract(be, opts, "test.sol:Test", "initial value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	receipt, err := be.TransactionReceipt(context.Background(), tx.Hash())
+	require.Nil(err)
+	require.Equal(crypto.CreateAddress(whale, 1), receipt.ContractAddress)
+	require.Equal(receipt.ContractAddress, c.Address())
+
+	_, err = c.Transact(opts, "setValue", "new value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	value, err := c.Call("value")
+	require.Nil(err)
+	require.Equal([]interface{}{"new value"}, value)
+
+	be.StopImpersonating(whale)
+	require.True(errors.Is(transfer(), ethertest.ErrInvalidSender))
+
+	// impersonated contracts can send transactions
+	require.Nil(be.SetBalance(c.Address(), ethertest.EthToWei(1)))
+	be.Impersonate(c.Address())
+	tx, err = c.Transact(ethertest.ImpersonatedTransactOpts(be, c.Address(), ethertest.WithGasLimit(100000)), "setValue", "value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	receipt, err = be.TransactionReceipt(context.Background(), tx.Hash())
+	require.Nil(err)
+	require.Equal(uint64(1), receipt.Status)
+	value, err = c.Call("value")
+	require.Nil(err)
+	require.Equal([]interface{}{"value"}, value)
+
+	// signed transactions can't be sent from addresses with code
+	owner := ethertest.NewAccount()
+	require.Nil(be.SetBalance(owner.Address(), ethertest.EthToWei(1)))
+	require.Nil(be.SetCode(owner.Address(), []byte{0x00}))
+	_, err = c.Transact(owner.TransactOpts(be, ethertest.WithGasLimit(100000)), "setValue", "value")
+	require.True(errors.Is(err, ethertest.ErrSenderNoEOA))
+}
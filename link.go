@@ -0,0 +1,191 @@
+package ethertest
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// placeholderLength is the number of hex characters solc uses for a library placeholder.
+const placeholderLength = 2 * common.AddressLength
+
+// linkReference is a location in the bytecode where an address of a library
+// has to be inserted before the bytecode can be deployed.
+type linkReference struct {
+	library string
+	offset  int
+}
+
+// libraryPlaceholder returns the placeholder solc >= 0.5 uses for a library with the
+// fully qualified name (e.g. "lib.sol:Math").
+func libraryPlaceholder(name string) string {
+	return "__$" + hex.EncodeToString(crypto.Keccak256([]byte(name)))[:34] + "$__"
+}
+
+// legacyLibraryPlaceholder returns the placeholder solc < 0.5 uses for a library
+// with the fully qualified name: the name truncated or padded with underscores
+// to 36 characters, enclosed in "__".
+func legacyLibraryPlaceholder(name string) string {
+	p := name + strings.Repeat("_", placeholderLength-4)
+	return "__" + p[:placeholderLength-4] + "__"
+}
+
+// parseBytecode decodes hex encoded bytecode produced by solc.
+// Library placeholders are replaced with zero addresses and returned as link references.
+// libraries contains fully qualified names of all contracts that can be linked.
+func parseBytecode(code string, libraries []string) ([]byte, []linkReference, error) {
+
+	refs := []linkReference{}
+
+	for i := strings.Index(code, "__"); i >= 0; i = strings.Index(code, "__") {
+		if i%2 != 0 || i+placeholderLength > len(code) {
+			return nil, nil, fmt.Errorf("malformed library placeholder at offset %d", i/2)
+		}
+		placeholder := code[i : i+placeholderLength]
+
+		library := ""
+		for _, l := range libraries {
+			if placeholder == libraryPlaceholder(l) || placeholder == legacyLibraryPlaceholder(l) {
+				library = l
+				break
+			}
+		}
+		if library == "" {
+			return nil, nil, fmt.Errorf("could not find library for placeholder %q", placeholder)
+		}
+
+		refs = append(refs, linkReference{library: library, offset: i / 2})
+		code = code[:i] + strings.Repeat("0", placeholderLength) + code[i+placeholderLength:]
+	}
+
+	binary, err := hex.DecodeString(code)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return binary, refs, nil
+}
+
+// linkBytecode returns a copy of binary with all link references replaced by
+// the library addresses.
+func linkBytecode(binary []byte, refs []linkReference, addresses map[string]common.Address) ([]byte, error) {
+	linked := make([]byte, len(binary))
+	copy(linked, binary)
+	for _, r := range refs {
+		a, found := addresses[r.library]
+		if !found {
+			return nil, fmt.Errorf("library %q is not deployed", r.library)
+		}
+		copy(linked[r.offset:], a[:])
+	}
+	return linked, nil
+}
+
+// isLibraryRuntime checks if the runtime bytecode starts with the call protection
+// of a library (PUSH20 <address> ADDRESS EQ). The address is inserted when the library
+// is deployed, so it will differ from the one in the bytecode generated by solc.
+func isLibraryRuntime(binary []byte) bool {
+	if len(binary) < common.AddressLength+3 {
+		return false
+	}
+	if binary[0] != 0x73 {
+		return false
+	}
+	if common.BytesToAddress(binary[1:common.AddressLength+1]) != (common.Address{}) {
+		return false
+	}
+	return binary[common.AddressLength+1] == 0x30 && binary[common.AddressLength+2] == 0x14
+}
+
+// LinkLibrary registers the address of a library that has been deployed on the backend.
+// Bytecode of contracts depending on that library will be linked to the given address.
+func (t *TestRig) LinkLibrary(be TestBackend, name string, address common.Address) error {
+	ib, err := t.interceptingBackend(be)
+	if err != nil {
+		return err
+	}
+	_, err = t.contract(name)
+	if err != nil {
+		return err
+	}
+	ib.libraries[name] = address
+	return nil
+}
+
+// DeployLibrary deploys the library with the fully qualified name (e.g. "lib.sol:Math")
+// on the backend and links it to all contracts deployed afterwards.
+// Libraries it depends on that are not yet deployed on the backend are deployed first.
+func (t *TestRig) DeployLibrary(be TestBackend, opts *bind.TransactOpts, name string) (common.Address, error) {
+	ib, err := t.interceptingBackend(be)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	code, err := t.LinkBytecode(be, opts, name)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	address, _, _, err := bind.DeployContract(opts, abi.ABI{}, code, be)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	ib.libraries[name] = address
+	return address, nil
+}
+
+// LinkBytecode returns deployable bytecode of the contract with the fully qualified name
+// with all library placeholders replaced by addresses of libraries on the backend.
+// Libraries that are not yet deployed on the backend are deployed using opts.
+func (t *TestRig) LinkBytecode(be TestBackend, opts *bind.TransactOpts, name string) ([]byte, error) {
+	ib, err := t.interceptingBackend(be)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := t.contract(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range c.linkReferences {
+		_, found := ib.libraries[r.library]
+		if found {
+			continue
+		}
+		_, err = t.DeployLibrary(be, opts, r.library)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return linkBytecode(c.bytecode, c.linkReferences, ib.libraries)
+}
+
+func (t *TestRig) contract(name string) (*contract, error) {
+	c, found := t.contracts[name]
+	if !found {
+		available := []string{}
+		for n := range t.contracts {
+			available = append(available, n)
+		}
+		sort.Strings(available)
+		return nil, fmt.Errorf("Could not find contract %q, available: %q", name, available)
+	}
+	return c, nil
+}
+
+func (t *TestRig) interceptingBackend(be TestBackend) (*interceptingBackend, error) {
+	ib, ok := be.(*interceptingBackend)
+	if !ok || ib.tr != t {
+		return nil, fmt.Errorf("backend was not created by this test rig")
+	}
+	return ib, nil
+}
@@ -0,0 +1,77 @@
+package ethertest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkBytecode(t *testing.T) {
+	require := require.New(t)
+
+	libraries := []string{"lib.sol:Math", "lib.sol:Strings"}
+	code := "6080" + "73" + libraryPlaceholder("lib.sol:Strings") + "6000" + "73" + legacyLibraryPlaceholder("lib.sol:Math") + "00"
+
+	binary, refs, err := parseBytecode(code, libraries)
+	require.Nil(err)
+	require.Equal([]linkReference{{library: "lib.sol:Strings", offset: 3}, {library: "lib.sol:Math", offset: 26}}, refs)
+	require.Equal(common.Hex2Bytes("6080"+"73"+strings.Repeat("00", 20)+"6000"+"73"+strings.Repeat("00", 20)+"00"), binary)
+
+	_, err = linkBytecode(binary, refs, map[string]common.Address{"lib.sol:Math": common.HexToAddress("0x1")})
+	require.EqualError(err, `library "lib.sol:Strings" is not deployed`)
+
+	linked, err := linkBytecode(binary, refs, map[string]common.Address{
+		"lib.sol:Math":    common.HexToAddress("0x1"),
+		"lib.sol:Strings": common.HexToAddress("0x2"),
+	})
+	require.Nil(err)
+	require.Equal(common.Hex2Bytes("6080"+"73"+strings.Repeat("00", 19)+"02"+"6000"+"73"+strings.Repeat("00", 19)+"01"+"00"), linked)
+
+	_, _, err = parseBytecode("6080"+libraryPlaceholder("other.sol:Lib"), libraries)
+	require.NotNil(err)
+
+	_, _, err = parseBytecode("60zz", libraries)
+	require.NotNil(err)
+}
+
+func TestLegacyLibraryPlaceholder(t *testing.T) {
+	require := require.New(t)
+	require.Equal("__lib.sol:Math__________________________", legacyLibraryPlaceholder("lib.sol:Math"))
+	require.Equal("__contracts/libraries/arithmetic.sol:M__", legacyLibraryPlaceholder("contracts/libraries/arithmetic.sol:Math"))
+	require.Len(legacyLibraryPlaceholder("contracts/libraries/arithmetic.sol:Math"), placeholderLength)
+}
+
+func TestIsLibraryRuntime(t *testing.T) {
+	require := require.New(t)
+	require.True(isLibraryRuntime(common.Hex2Bytes("73" + strings.Repeat("00", 20) + "3014608060")))
+	require.False(isLibraryRuntime(common.Hex2Bytes("6080604052600080fd")))
+}
+
+func TestRevertToRestoresLibraries(t *testing.T) {
+	var tr = NewTestRig()
+	var owner = NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/linked/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+	ib := be.(*interceptingBackend)
+
+	snapshot := be.Snapshot()
+	for i := 0; i < 2; i++ {
+		library, err := tr.DeployLibrary(be, owner.TransactOpts(be), "lib/math.sol:Math")
+		require.Nil(err)
+		require.Nil(be.Commit())
+		later := be.Snapshot()
+		require.Equal(map[string]common.Address{"lib/math.sol:Math": library}, ib.snapshots[later])
+
+		require.Nil(be.RevertTo(snapshot))
+		require.Empty(ib.libraries)
+		require.Len(ib.snapshots, 1)
+		require.Contains(ib.snapshots, snapshot)
+	}
+}
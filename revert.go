@@ -0,0 +1,274 @@
+package ethertest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// revert describes why a call or a transaction reverted.
+type revert struct {
+	// reason is the reason of require/revert with a message or the reason of a panic.
+	reason string
+	// data is the revert data of a custom error, nil if the revert has a reason.
+	data     []byte
+	location string
+}
+
+// customError is revert data decoded with the ABI of a registered contract.
+type customError struct {
+	name   string
+	inputs abi.Arguments
+	values []interface{}
+}
+
+func (e *customError) String() string {
+	return fmt.Sprintf("%s(%s)", e.name, formatArguments(e.inputs, e.values))
+}
+
+// TraceRevertLocations makes ExpectRevert re-execute failed transactions traced,
+// so the returned error contains the source line the transaction reverted at.
+// The re-executions are not part of the trace, the coverage or the gas usage.
+func (t *TestRig) TraceRevertLocations() *TestRig {
+	t.traceReverts = true
+	return t
+}
+
+// ExpectRevert executes f and returns an error if it didn't revert with the reason.
+// f can call contracts or send transactions, e.g. with bindings, or do both.
+// Transactions sent by f are mined and the first failed one is re-executed to get its revert reason.
+// An empty reason expects a revert without a reason.
+func (t *TestRig) ExpectRevert(be TestBackend, f func() error, reason string) error {
+	r, err := t.revertOf(be, f)
+	if err != nil {
+		return err
+	}
+	if r.data == nil && r.reason == reason {
+		return nil
+	}
+	return fmt.Errorf("expected revert with %q, got %s%s", reason, t.describeRevert(r), formatLocation(r.location))
+}
+
+// ExpectRevertWithCustomError executes f like ExpectRevert and returns an error
+// if it didn't revert with the custom error of a registered contract ABI.
+// If argument values are given, they have to match the arguments of the error.
+func (t *TestRig) ExpectRevertWithCustomError(be TestBackend, f func() error, name string, args ...interface{}) error {
+	r, err := t.revertOf(be, f)
+	if err != nil {
+		return err
+	}
+
+	expected := name
+	if len(args) > 0 {
+		expected = Emitted(name, args...).String()
+	}
+	if ce := t.decodeCustomError(r.data); ce != nil && ce.name == name {
+		if len(args) == 0 {
+			return nil
+		}
+		if len(args) == len(ce.values) {
+			matching := true
+			for i, v := range args {
+				matching = matching && valuesEqual(v, ce.values[i])
+			}
+			if matching {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("expected revert with custom error %s, got %s%s", expected, t.describeRevert(r), formatLocation(r.location))
+}
+
+// revertOf executes f and returns why the call or the first failed transaction reverted.
+func (t *TestRig) revertOf(be TestBackend, f func() error) (*revert, error) {
+	ib, err := t.interceptingBackend(be)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := t.tracedSteps()
+	txs, err := ib.record(f)
+	if err != nil {
+		r, ok := parseRevert(err)
+		if !ok {
+			return nil, fmt.Errorf("expected revert, got error: %v", err)
+		}
+		r.location = t.executedSince(steps)
+		return r, nil
+	}
+
+	for _, tx := range txs {
+		receipt, err := be.TransactionReceipt(context.Background(), tx.Hash())
+		if err != nil {
+			return nil, err
+		}
+		if receipt == nil {
+			if err := be.Commit(); err != nil {
+				return nil, err
+			}
+			receipt, err = be.TransactionReceipt(context.Background(), tx.Hash())
+			if err != nil {
+				return nil, err
+			}
+			if receipt == nil {
+				return nil, ErrTransactionPending
+			}
+		}
+		if receipt.Status == types.ReceiptStatusSuccessful {
+			continue
+		}
+
+		location := &revertTracer{TestRig: t}
+		if t.traceReverts {
+			err = be.TraceTransactionError(context.Background(), tx.Hash(), location)
+		} else {
+			err = be.TransactionError(context.Background(), tx.Hash())
+		}
+		r, ok := parseRevert(err)
+		if !ok {
+			return nil, fmt.Errorf("expected revert, transaction %s failed with: %v", tx.Hash().Hex(), err)
+		}
+		r.location = location.location
+		return r, nil
+	}
+
+	if len(txs) == 0 {
+		return nil, errors.New("expected revert, but the call was successful")
+	}
+	return nil, errors.New("expected revert, but all transactions were successful")
+}
+
+// parseRevert extracts the revert reason or data from the error of a call or a gas estimation.
+// Bindings only keep the message of gas estimation errors, so the message is parsed
+// if the error doesn't carry the revert data.
+func parseRevert(err error) (*revert, bool) {
+	if err == nil {
+		return nil, false
+	}
+
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if s, ok := dataErr.ErrorData().(string); ok {
+			if data, err := hexutil.Decode(s); err == nil {
+				return revertFromData(data), true
+			}
+		}
+	}
+
+	const reverted = "execution reverted"
+	msg := err.Error()
+	i := strings.Index(msg, reverted)
+	if i == -1 {
+		return nil, false
+	}
+	reason := strings.TrimPrefix(msg[i+len(reverted):], ": ")
+	if hex := strings.TrimPrefix(reason, "custom error "); hex != reason {
+		if data, err := hexutil.Decode(hex); err == nil {
+			return &revert{data: data}, true
+		}
+	}
+	return &revert{reason: reason}, true
+}
+
+// revertFromData returns the revert with the reason or the custom error of the revert data.
+func revertFromData(data []byte) *revert {
+	if reason, err := abi.UnpackRevert(data); err == nil {
+		return &revert{reason: reason}
+	}
+	if len(data) == 0 {
+		return &revert{}
+	}
+	return &revert{data: data}
+}
+
+// decodeCustomError decodes the revert data with the errors of the registered contract ABIs.
+func (t *TestRig) decodeCustomError(data []byte) *customError {
+	if len(data) < 4 {
+		return nil
+	}
+	for _, a := range t.registeredABIs() {
+		for _, e := range a.Errors {
+			if !bytes.Equal(e.ID[:4], data[:4]) {
+				continue
+			}
+			values, err := e.Inputs.Unpack(data[4:])
+			if err != nil {
+				continue
+			}
+			return &customError{name: e.Name, inputs: e.Inputs, values: values}
+		}
+	}
+	return nil
+}
+
+func (t *TestRig) describeRevert(r *revert) string {
+	if r.data == nil {
+		if r.reason == "" {
+			return "revert without reason"
+		}
+		return fmt.Sprintf("%q", r.reason)
+	}
+	if ce := t.decodeCustomError(r.data); ce != nil {
+		return fmt.Sprintf("custom error %s", ce)
+	}
+	return fmt.Sprintf("unknown custom error %s", hexutil.Encode(r.data))
+}
+
+// revertTracer traces the re-execution of a failed transaction for the location of the revert.
+// The steps of the re-execution are removed from the trace when it ends,
+// so the transaction is only traced once, when it was mined.
+type revertTracer struct {
+	*TestRig
+	contracts, steps, executions int
+	location                     string
+}
+
+func (r *revertTracer) CaptureTxStart(gasLimit uint64) {
+	r.TestRig.CaptureTxStart(gasLimit)
+	trace := r.tracer.trace
+	r.contracts, r.steps, r.executions = len(trace.Contracts), len(trace.Steps), len(trace.Executions)
+}
+
+func (r *revertTracer) CaptureTxEnd(restGas uint64) {
+	r.tracer.executionEnded(restGas)
+	trace := r.tracer.trace
+	if len(trace.Steps) > r.steps {
+		r.location = trace.LastStep()
+	}
+	trace.Contracts, trace.Steps, trace.Executions = trace.Contracts[:r.contracts], trace.Steps[:r.steps], trace.Executions[:r.executions]
+	r.mu.Unlock()
+}
+
+// tracedSteps returns the number of steps in the trace.
+func (t *TestRig) tracedSteps() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.tracer.trace.Steps)
+}
+
+// executedSince returns the last executed source line if code of a registered contract
+// has been executed since the trace had the number of steps.
+func (t *TestRig) executedSince(steps int) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.tracer.trace.Steps) == steps {
+		return ""
+	}
+	return t.tracer.trace.LastStep()
+}
+
+func formatLocation(location string) string {
+	if location == "" || location == "N/A" {
+		return ""
+	}
+	return "\nreverted at " + strings.TrimSuffix(location, "\n")
+}
@@ -0,0 +1,143 @@
+package ethertest_test
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+	"github.com/tokencard/ethertest"
+)
+
+const customErrorsABI = `[
+	{"type":"error","name":"InsufficientBalance","inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}]}
+]`
+
+// revertCode returns code that reverts every call with the data.
+func revertCode(data []byte) []byte {
+	var code []byte
+	for i := 0; i < len(data); i += 32 {
+		word := make([]byte, 32)
+		copy(word, data[i:])
+		code = append(code, 0x7f)
+		code = append(code, word...)
+		code = append(code, 0x60, byte(i), 0x52)
+	}
+	return append(code, 0x60, byte(len(data)), 0x60, 0x00, 0xfd)
+}
+
+func TestExpectRevert(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var failing = common.HexToAddress("0xfa11")
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	c, _, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+	be.Commit()
+
+	call := func() error {
+		_, err := c.Call("willFail")
+		return err
+	}
+	require.Nil(tr.ExpectRevert(be, call, "will fail"))
+
+	err = tr.ExpectRevert(be, call, "other reason")
+	require.NotNil(err)
+	require.True(strings.HasPrefix(err.Error(), `expected revert with "other reason", got "will fail"`+"\nreverted at "), err.Error())
+
+	estimated := func() error {
+		_, err := c.Transact(owner.TransactOpts(be), "willFail")
+		return err
+	}
+	require.Nil(tr.ExpectRevert(be, estimated, "will fail"))
+
+	mined := func() error {
+		_, err := c.Transact(owner.TransactOpts(be, ethertest.WithGasLimit(100000)), "willFail")
+		return err
+	}
+	require.Nil(tr.ExpectRevert(be, mined, "will fail"))
+
+	// the location of a mined transaction is only traced with TraceRevertLocations
+	err = tr.ExpectRevert(be, mined, "other reason")
+	require.EqualError(err, `expected revert with "other reason", got "will fail"`)
+
+	successful := func() error {
+		_, err := c.Transact(owner.TransactOpts(be), "setValue", "new value")
+		return err
+	}
+	require.EqualError(tr.ExpectRevert(be, successful, "will fail"), "expected revert, but all transactions were successful")
+
+	parsed, err := abi.JSON(strings.NewReader(customErrorsABI))
+	require.Nil(err)
+	tr.AddABI(parsed)
+	insufficientBalance := parsed.Errors["InsufficientBalance"]
+	data, err := insufficientBalance.Inputs.Pack(big.NewInt(1), big.NewInt(2))
+	require.Nil(err)
+	require.Nil(be.SetCode(failing, revertCode(append(insufficientBalance.ID[:4], data...))))
+	be.Commit()
+
+	failingCall := func() error {
+		_, err := be.CallContract(context.Background(), ethereum.CallMsg{To: &failing}, nil)
+		return err
+	}
+	require.Nil(tr.ExpectRevertWithCustomError(be, failingCall, "InsufficientBalance"))
+	require.Nil(tr.ExpectRevertWithCustomError(be, failingCall, "InsufficientBalance", 1, big.NewInt(2)))
+
+	err = tr.ExpectRevertWithCustomError(be, failingCall, "InsufficientBalance", 1, 3)
+	require.NotNil(err)
+	require.Equal("expected revert with custom error InsufficientBalance(1, 3), got custom error InsufficientBalance(available: 1, required: 2)", err.Error())
+
+	err = tr.ExpectRevert(be, failingCall, "will fail")
+	require.NotNil(err)
+	require.Contains(err.Error(), "got custom error InsufficientBalance(available: 1, required: 2)")
+
+	err = tr.ExpectRevertWithCustomError(be, call, "InsufficientBalance")
+	require.NotNil(err)
+	require.Contains(err.Error(), `got "will fail"`)
+}
+
+func TestTraceRevertLocations(t *testing.T) {
+	var tr = ethertest.NewTestRig().TraceRevertLocations()
+	var owner = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	// without gas estimations, the trace only contains the deployment and the transaction
+	c, _, err := tr.DeployContract(be, owner.TransactOpts(be, ethertest.WithGasLimit(1000000)), "test.sol:Test", "initial value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	mined := func() error {
+		_, err := c.Transact(owner.TransactOpts(be, ethertest.WithGasLimit(100000)), "willFail")
+		return err
+	}
+	revertErr := tr.ExpectRevert(be, mined, "other reason")
+	require.NotNil(revertErr)
+	require.True(strings.HasPrefix(revertErr.Error(), `expected revert with "other reason", got "will fail"`+"\nreverted at subdir/super.sol:12\n"), revertErr.Error())
+
+	// the re-execution of the transaction is not traced
+	saved := &bytes.Buffer{}
+	require.Nil(tr.SaveTrace(saved))
+	trace, err := ethertest.LoadTrace(saved)
+	require.Nil(err)
+	require.Len(trace.Executions, 2)
+	require.Equal("execution reverted", trace.Executions[1].Error)
+	require.True(strings.HasSuffix(revertErr.Error(), strings.TrimSuffix(trace.LastStep(), "\n")), trace.LastStep())
+}
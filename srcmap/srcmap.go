@@ -6,14 +6,25 @@ import (
 	"strings"
 )
 
+// Entry is the source mapping of an instruction.
 type Entry struct {
+	// S is the byte offset of the start of the range in the source file, -1 for generated code.
 	S int
+	// L is the length of the source range in bytes, -1 for generated code.
 	L int
+	// F is the index of the source file, -1 if the instruction isn't mapped to a source file.
 	F int
+	// J is the jump type: "i" into a function, "o" out of a function or "-" for a regular jump.
 	J string
+	// M is the modifier depth, increased when the placeholder statement "_" of a modifier is entered.
+	M int
 }
 
+// String returns the entry as s:l:f:j, followed by :m if the modifier depth isn't 0.
 func (e Entry) String() string {
+	if e.M != 0 {
+		return fmt.Sprintf("%d:%d:%d:%s:%d", e.S, e.L, e.F, e.J, e.M)
+	}
 	return fmt.Sprintf("%d:%d:%d:%s", e.S, e.L, e.F, e.J)
 }
 
@@ -27,48 +38,73 @@ func (m Map) String() string {
 	return strings.Join(parts, ";")
 }
 
-func nextMapEntry(el string, prev Entry) (Entry, error) {
+// fieldNames are the names of the fields of an entry, in the order of the compressed format.
+var fieldNames = []string{"start offset", "length", "source index", "jump type", "modifier depth"}
+
+// ParseError is returned by Uncompress for an invalid entry.
+type ParseError struct {
+	// Entry is the index of the invalid entry.
+	Entry int
+	// Field is the name of the invalid field, empty if the entry has too many fields.
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("srcmap entry %d %q: %s", e.Entry, e.Value, e.Err)
+	}
+	return fmt.Sprintf("srcmap entry %d: invalid %s %q: %s", e.Entry, e.Field, e.Value, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func nextMapEntry(index int, el string, prev Entry) (Entry, error) {
 
 	if el == "" {
 		return prev, nil
 	}
 
 	parts := strings.Split(el, ":")
+	if len(parts) > len(fieldNames) {
+		return Entry{}, &ParseError{Entry: index, Value: el, Err: fmt.Errorf("%d fields, expected at most %d", len(parts), len(fieldNames))}
+	}
 
 	r := prev
 
-	if len(parts) >= 1 {
-		if parts[0] != "" {
-			var err error
-			r.S, err = strconv.Atoi(parts[0])
-			if err != nil {
-				panic(err)
-			}
+	number := func(field int, min int) (int, error) {
+		n, err := strconv.Atoi(parts[field])
+		if err != nil {
+			return 0, &ParseError{Entry: index, Field: fieldNames[field], Value: parts[field], Err: err}
+		}
+		if n < min {
+			return 0, &ParseError{Entry: index, Field: fieldNames[field], Value: parts[field], Err: fmt.Errorf("has to be at least %d", min)}
 		}
+		return n, nil
 	}
 
-	if len(parts) >= 2 {
-		if parts[1] != "" {
-			var err error
-			r.L, err = strconv.Atoi(parts[1])
-			if err != nil {
-				return Entry{}, err
-			}
+	var err error
+	for i, part := range parts {
+		if part == "" {
+			continue
 		}
-	}
-	if len(parts) >= 3 {
-		if parts[2] != "" {
-			var err error
-			r.F, err = strconv.Atoi(parts[2])
-			if err != nil {
-				return Entry{}, err
-			}
+		switch i {
+		case 0:
+			r.S, err = number(i, -1)
+		case 1:
+			r.L, err = number(i, -1)
+		case 2:
+			r.F, err = number(i, -1)
+		case 3:
+			r.J = part
+		case 4:
+			r.M, err = number(i, 0)
 		}
-	}
-
-	if len(parts) >= 4 {
-		if parts[3] != "" {
-			r.J = parts[3]
+		if err != nil {
+			return Entry{}, err
 		}
 	}
 
@@ -77,12 +113,17 @@ func nextMapEntry(el string, prev Entry) (Entry, error) {
 }
 
 // Uncompress will convert srcmap string into slice of srcmap entries.
+// Entries have the format s:l:f:j:m, empty and missing fields are copied from the previous entry.
+// An invalid entry is reported with a *ParseError. An empty string is an empty map.
 func Uncompress(compressed string) (Map, error) {
+	if compressed == "" {
+		return Map{}, nil
+	}
 	els := strings.Split(compressed, ";")
 	prev := Entry{}
 	res := []Entry{}
-	for _, el := range els {
-		sme, err := nextMapEntry(el, prev)
+	for i, el := range els {
+		sme, err := nextMapEntry(i, el, prev)
 		if err != nil {
 			return nil, err
 		}
@@ -93,3 +134,38 @@ func Uncompress(compressed string) (Map, error) {
 	}
 	return res, nil
 }
+
+// Compress converts srcmap entries into a srcmap string in the format of the compiler,
+// leaving out the fields that are the same as in the previous entry.
+// The start offset, length and source index of the first entry are always included.
+// Like in the compiler's format, the jump type can't change back to empty.
+func Compress(m Map) string {
+	els := make([]string, len(m))
+	prev := Entry{}
+	for i, e := range m {
+		fields := make([]string, len(fieldNames))
+		if i == 0 || e.S != prev.S {
+			fields[0] = strconv.Itoa(e.S)
+		}
+		if i == 0 || e.L != prev.L {
+			fields[1] = strconv.Itoa(e.L)
+		}
+		if i == 0 || e.F != prev.F {
+			fields[2] = strconv.Itoa(e.F)
+		}
+		if e.J != prev.J {
+			fields[3] = e.J
+		}
+		if e.M != prev.M {
+			fields[4] = strconv.Itoa(e.M)
+		}
+
+		n := len(fields)
+		for n > 0 && fields[n-1] == "" {
+			n--
+		}
+		els[i] = strings.Join(fields[:n], ":")
+		prev = e
+	}
+	return strings.Join(els, ";")
+}
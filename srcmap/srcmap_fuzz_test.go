@@ -0,0 +1,43 @@
+package srcmap_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tokencard/ethertest/srcmap"
+)
+
+func FuzzUncompress(f *testing.F) {
+	for _, s := range []string{
+		"",
+		"1:2:3:abc;;:4:5:def",
+		"26:85:0:-:0;;;;:24::i;:-1:::1",
+		"1:2:-1:o;;x:1",
+		"1:2:3:i:0:1",
+		":::::",
+		";;;",
+		"-1",
+		"99999999999999999999",
+	} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, compressed string) {
+		m, err := srcmap.Uncompress(compressed)
+		if err != nil {
+			if m != nil {
+				t.Fatalf("got entries %v and error %v", m, err)
+			}
+			return
+		}
+
+		recompressed := srcmap.Compress(m)
+		again, err := srcmap.Uncompress(recompressed)
+		if err != nil {
+			t.Fatalf("could not uncompress %q compressed from %q: %v", recompressed, compressed, err)
+		}
+		if !reflect.DeepEqual(m, again) {
+			t.Fatalf("%q uncompressed to %v, compressed to %q and uncompressed to %v", compressed, m, recompressed, again)
+		}
+	})
+}
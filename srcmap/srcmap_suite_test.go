@@ -1,6 +1,7 @@
 package srcmap_test
 
 import (
+	"errors"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
@@ -56,4 +57,96 @@ var _ = Describe("Uncompress", func() {
 		Expect(m.String()).To(Equal("1:2:3:abc;4:5:6:abc"))
 	})
 
+	It("Should parse the modifier depth", func() {
+		m, err := srcmap.Uncompress("1:2:3:i:1;4:5;::::0")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m).To(Equal(srcmap.Map{
+			{S: 1, L: 2, F: 3, J: "i", M: 1},
+			{S: 4, L: 5, F: 3, J: "i", M: 1},
+			{S: 4, L: 5, F: 3, J: "i", M: 0},
+		}))
+		Expect(m.String()).To(Equal("1:2:3:i:1;4:5:3:i:1;4:5:3:i"))
+	})
+
+	It("Should parse an entry that only changes the modifier depth", func() {
+		m, err := srcmap.Uncompress("1:2:3:i;::::1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m).To(Equal(srcmap.Map{
+			{S: 1, L: 2, F: 3, J: "i", M: 0},
+			{S: 1, L: 2, F: 3, J: "i", M: 1},
+		}))
+		Expect(srcmap.Compress(m)).To(Equal("1:2:3:i;::::1"))
+	})
+
+	It("Should return an empty map for an empty string", func() {
+		m, err := srcmap.Uncompress("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m).To(BeEmpty())
+	})
+
+	It("Should accept -1 for generated code", func() {
+		m, err := srcmap.Uncompress("1:2:3:o;-1:-1:-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m[1]).To(Equal(srcmap.Entry{S: -1, L: -1, F: -1, J: "o"}))
+	})
+
+	It("Should return an error with the index of an entry with an invalid start offset", func() {
+		_, err := srcmap.Uncompress("1:2:3:i;;x:1")
+		Expect(err).To(MatchError(`srcmap entry 2: invalid start offset "x": strconv.Atoi: parsing "x": invalid syntax`))
+		var parseErr *srcmap.ParseError
+		Expect(errors.As(err, &parseErr)).To(BeTrue())
+		Expect(parseErr.Entry).To(Equal(2))
+		Expect(parseErr.Field).To(Equal("start offset"))
+	})
+
+	It("Should return an error for negative values", func() {
+		_, err := srcmap.Uncompress("1:-2:3:i")
+		Expect(err).To(MatchError(`srcmap entry 0: invalid length "-2": has to be at least -1`))
+		_, err = srcmap.Uncompress("1:2:-2:i")
+		Expect(err).To(MatchError(`srcmap entry 0: invalid source index "-2": has to be at least -1`))
+		_, err = srcmap.Uncompress("1:2:3:i:-1")
+		Expect(err).To(MatchError(`srcmap entry 0: invalid modifier depth "-1": has to be at least 0`))
+	})
+
+	It("Should return an error for entries with too many fields", func() {
+		_, err := srcmap.Uncompress("1:2:3:i;1:2:3:i:0:1")
+		Expect(err).To(MatchError(`srcmap entry 1 "1:2:3:i:0:1": 6 fields, expected at most 5`))
+	})
+
+})
+
+var _ = Describe("Compress", func() {
+	It("Should leave out fields that are the same as in the previous entry", func() {
+		m := srcmap.Map{
+			{S: 1, L: 2, F: 0, J: "-"},
+			{S: 1, L: 2, F: 0, J: "-"},
+			{S: 4, L: 2, F: 0, J: "i"},
+			{S: 4, L: 2, F: -1, J: "i", M: 1},
+			{S: 4, L: 3, F: -1, J: "i", M: 1},
+		}
+		Expect(srcmap.Compress(m)).To(Equal("1:2:0:-;;4:::i;::-1::1;:3"))
+	})
+
+	It("Should always include start offset, length and source index of the first entry", func() {
+		Expect(srcmap.Compress(srcmap.Map{{}})).To(Equal("0:0:0"))
+		Expect(srcmap.Compress(nil)).To(Equal(""))
+	})
+
+	It("Should compress uncompressed entries to the same map", func() {
+		compressed := "26:85:0:-:0;;;:24::i;;::-1::1"
+		m, err := srcmap.Uncompress(compressed)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(srcmap.Compress(m)).To(Equal("26:85:0:-;;;:24::i;;::-1::1"))
+		again, err := srcmap.Uncompress(srcmap.Compress(m))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(again).To(Equal(m))
+	})
+
+	It("Should compress and uncompress empty maps", func() {
+		Expect(srcmap.Compress(srcmap.Map{})).To(Equal(""))
+		m, err := srcmap.Uncompress(srcmap.Compress(srcmap.Map{}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m).To(Equal(srcmap.Map{}))
+		Expect(srcmap.Compress(m)).To(Equal(""))
+	})
 })
@@ -0,0 +1,89 @@
+package ethertest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/tokencard/ethertest/backends"
+)
+
+// FormatStateDiff formats the changes of the accounts made by a transaction, one change per line:
+//
+//	test.sol:Test(0x...)
+//	  storage value (slot 0): 0x696e... → 0x6e65...
+//
+// Storage slots of registered contracts are named after their state variables
+// if the storage layout is included in the combined-json or added with AddStorageLayout.
+func (t *TestRig) FormatStateDiff(diff backends.StateDiff) string {
+	addresses := make([]common.Address, 0, len(diff))
+	for a := range diff {
+		addresses = append(addresses, a)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i].Bytes(), addresses[j].Bytes()) < 0
+	})
+
+	s := &strings.Builder{}
+	for _, a := range addresses {
+		d := diff[a]
+		fmt.Fprintf(s, "%s\n", t.formatAddress(a))
+		if d.Before.Balance.Cmp(d.After.Balance) != 0 {
+			fmt.Fprintf(s, "  balance: %s → %s\n", d.Before.Balance, d.After.Balance)
+		}
+		if d.Before.Nonce != d.After.Nonce {
+			fmt.Fprintf(s, "  nonce: %d → %d\n", d.Before.Nonce, d.After.Nonce)
+		}
+		if !bytes.Equal(d.Before.Code, d.After.Code) {
+			fmt.Fprintf(s, "  code: %d bytes → %d bytes\n", len(d.Before.Code), len(d.After.Code))
+		}
+
+		slots := make([]common.Hash, 0, len(d.Storage))
+		for slot := range d.Storage {
+			slots = append(slots, slot)
+		}
+		sort.Slice(slots, func(i, j int) bool {
+			return bytes.Compare(slots[i].Bytes(), slots[j].Bytes()) < 0
+		})
+
+		var layout *storageLayout
+		if c := t.contractAt(a); c != nil {
+			layout = t.layoutOf(c.name)
+		}
+		for _, slot := range slots {
+			name := formatSlot(slot)
+			if layout != nil {
+				if variable := layout.slotName(slot); variable != "" {
+					name = fmt.Sprintf("%s (%s)", variable, name)
+				}
+			}
+			fmt.Fprintf(s, "  storage %s: %s → %s\n", name, d.Storage[slot].Before.Hex(), d.Storage[slot].After.Hex())
+		}
+	}
+	return s.String()
+}
+
+// PrintStateDiff writes the formatted StateDiff of the mined transaction to w.
+func (t *TestRig) PrintStateDiff(w io.Writer, be TestBackend, tx *types.Transaction) error {
+	diff, err := be.StateDiff(context.Background(), tx.Hash())
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, t.FormatStateDiff(diff))
+	return err
+}
+
+// formatSlot formats small slot numbers as decimal numbers, and slots computed by hashing as hex.
+func formatSlot(slot common.Hash) string {
+	s := new(big.Int).SetBytes(slot.Bytes())
+	if s.IsUint64() {
+		return fmt.Sprintf("slot %d", s.Uint64())
+	}
+	return fmt.Sprintf("slot %s", slot.Hex())
+}
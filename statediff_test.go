@@ -0,0 +1,202 @@
+package ethertest_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+	"github.com/tokencard/ethertest"
+)
+
+func TestStateDiff(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	c, _, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	tx, err := c.Transact(owner.TransactOpts(be), "setValue", "new value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	diff, err := be.StateDiff(context.Background(), tx.Hash())
+	require.Nil(err)
+
+	sender := diff[owner.Address()]
+	require.NotNil(sender)
+	require.Equal(uint64(1), sender.Before.Nonce)
+	require.Equal(uint64(2), sender.After.Nonce)
+	require.Equal(1, sender.Before.Balance.Cmp(sender.After.Balance))
+	require.Empty(sender.Storage)
+
+	contract := diff[c.Address()]
+	require.NotNil(contract)
+	require.Equal(contract.Before.Balance, contract.After.Balance)
+	require.Len(contract.Storage, 1)
+	slot := contract.Storage[common.Hash{}]
+	require.Equal(shortString("initial value"), slot.Before)
+	require.Equal(shortString("new value"), slot.After)
+
+	formatted := tr.FormatStateDiff(diff)
+	require.Contains(formatted, "test.sol:Test("+c.Address().Hex()+")\n  storage value (slot 0): "+shortString("initial value").Hex()+" → "+shortString("new value").Hex()+"\n")
+	require.Contains(formatted, owner.Address().Hex()+"\n  balance: ")
+	require.Contains(formatted, "  nonce: 1 → 2\n")
+
+	long := strings.Repeat("a long value ", 4)
+	tx, err = c.Transact(owner.TransactOpts(be), "setValue", long)
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	out := &bytes.Buffer{}
+	require.Nil(tr.PrintStateDiff(out, be, tx))
+	require.Contains(out.String(), "  storage value (slot 0): ")
+	require.Contains(out.String(), "  storage value (data slot 0) (slot 0x290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e563): ")
+	require.Contains(out.String(), "  storage value (data slot 1) (slot 0x290decd9548b62a8d60345a988386fc84ba6bc95484008f6362f93160ef3e564): ")
+
+	tx, err = c.Transact(owner.TransactOpts(be, ethertest.WithGasLimit(100000)), "willFail")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	diff, err = be.StateDiff(context.Background(), tx.Hash())
+	require.Nil(err)
+	require.NotContains(diff, c.Address())
+	require.Contains(diff, owner.Address())
+
+	_, err = be.StateDiff(context.Background(), common.Hash{})
+	require.NotNil(err)
+}
+
+func TestStateDiffWithAddedStorageLayout(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	// replaces the layout of the combined-json, naming the first slot differently
+	require.Nil(tr.AddStorageLayout("test.sol:Test", []byte(`{
+  "storage": [
+    {"astId": 1, "contract": "test.sol:Test", "label": "description", "offset": 0, "slot": "0", "type": "t_string_storage"}
+  ],
+  "types": {
+    "t_string_storage": {"encoding": "bytes", "label": "string", "numberOfBytes": "32"}
+  }
+}`)))
+
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	c, _, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	tx, err := c.Transact(owner.TransactOpts(be), "setValue", "new value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	out := &bytes.Buffer{}
+	require.Nil(tr.PrintStateDiff(out, be, tx))
+	require.Contains(out.String(), "test.sol:Test("+c.Address().Hex()+")\n  storage description (slot 0): ")
+}
+
+func TestStateDiffOfPackedStaticArray(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	// the three elements are packed in the first slot, which leaves room for 16 of them
+	require.Nil(tr.AddStorageLayout("test.sol:Test", []byte(`{
+  "storage": [
+    {"astId": 1, "contract": "test.sol:Test", "label": "small", "offset": 0, "slot": "0", "type": "t_array(t_uint16)3_storage"}
+  ],
+  "types": {
+    "t_array(t_uint16)3_storage": {"base": "t_uint16", "encoding": "inplace", "label": "uint16[3]", "numberOfBytes": "32"},
+    "t_uint16": {"encoding": "inplace", "label": "uint16", "numberOfBytes": "2"}
+  }
+}`)))
+
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	c, _, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	tx, err := c.Transact(owner.TransactOpts(be), "setValue", "new value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	out := &bytes.Buffer{}
+	require.Nil(tr.PrintStateDiff(out, be, tx))
+	require.Contains(out.String(), "test.sol:Test("+c.Address().Hex()+")\n  storage small[0-2] (slot 0): ")
+}
+
+func TestStateDiffOfValueTransfers(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+	var beneficiary = ethertest.NewAccount()
+	var destructible = common.HexToAddress("0xde")
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	// the account receiving a plain transfer
+	opts := owner.TransactOpts(be)
+	opts.Value, opts.GasLimit = ethertest.EthToWei(1), 21000
+	tx, err := bind.NewBoundContract(receiver.Address(), abi.ABI{}, be, be, be).Transfer(opts)
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	diff, err := be.StateDiff(context.Background(), tx.Hash())
+	require.Nil(err)
+	require.Contains(diff, receiver.Address())
+	require.Equal(ethertest.EthToWei(1), diff[receiver.Address()].After.Balance)
+
+	// the beneficiary of SELFDESTRUCT
+	code := append([]byte{0x73}, beneficiary.Address().Bytes()...)
+	require.Nil(be.SetCode(destructible, append(code, 0xff)))
+	require.Nil(be.SetBalance(destructible, ethertest.EthToWei(2)))
+	require.Nil(be.Commit())
+
+	tx, err = bind.NewBoundContract(destructible, abi.ABI{}, be, be, be).RawTransact(owner.TransactOpts(be, ethertest.WithGasLimit(100000)), nil)
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	diff, err = be.StateDiff(context.Background(), tx.Hash())
+	require.Nil(err)
+	require.Contains(diff, beneficiary.Address())
+	require.Equal(0, diff[beneficiary.Address()].Before.Balance.Sign())
+	require.Equal(ethertest.EthToWei(2), diff[beneficiary.Address()].After.Balance)
+	require.Contains(diff, destructible)
+	require.Equal(0, diff[destructible].After.Balance.Sign())
+	require.Empty(diff[destructible].After.Code)
+}
+
+// shortString returns the storage slot of a string shorter than 32 bytes.
+func shortString(s string) common.Hash {
+	h := common.Hash{}
+	copy(h[:], s)
+	h[31] = byte(len(s) * 2)
+	return h
+}
@@ -0,0 +1,618 @@
+package ethertest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// maxDynamicLength is the maximal length of dynamic arrays, bytes and strings
+// whose data slots are named after the variable.
+const maxDynamicLength = 1 << 32
+
+// maxReadLength is the maximal number of elements of an array, or bytes of bytes and strings,
+// read as a whole by StorageReader, so a corrupt length in the storage doesn't exhaust the memory.
+const maxReadLength = 1 << 16
+
+// storageLayout is the storage layout of a contract generated by solc with --combined-json storage-layout.
+type storageLayout struct {
+	Storage []storageVariable      `json:"storage"`
+	Types   map[string]storageType `json:"types"`
+}
+
+type storageVariable struct {
+	Label  string `json:"label"`
+	Offset int    `json:"offset"`
+	Slot   string `json:"slot"`
+	Type   string `json:"type"`
+}
+
+type storageType struct {
+	id string
+	// Encoding is one of inplace, mapping, dynamic_array and bytes.
+	Encoding      string            `json:"encoding"`
+	Label         string            `json:"label"`
+	NumberOfBytes string            `json:"numberOfBytes"`
+	Base          string            `json:"base"`
+	Key           string            `json:"key"`
+	Value         string            `json:"value"`
+	Members       []storageVariable `json:"members"`
+}
+
+// slots returns the number of slots occupied by a value of the type.
+func (t storageType) slots() *big.Int {
+	size, ok := new(big.Int).SetString(t.NumberOfBytes, 10)
+	if !ok {
+		return big.NewInt(1)
+	}
+	size.Add(size, big.NewInt(31))
+	return size.Div(size, big.NewInt(32))
+}
+
+// size returns the number of bytes of a value of the type.
+func (t storageType) size() int {
+	size, ok := new(big.Int).SetString(t.NumberOfBytes, 10)
+	if !ok || !size.IsInt64() {
+		return 32
+	}
+	return int(size.Int64())
+}
+
+// slotName returns the names of the state variables stored in the slot,
+// e.g. "owner", "config.limit", "values[3]" or "name (data slot 1)" for the data of long strings.
+// Slots of mapping values can't be named, as the keys aren't known.
+func (l *storageLayout) slotName(slot common.Hash) string {
+	s := new(big.Int).SetBytes(slot.Bytes())
+	names := []string{}
+	for _, v := range l.Storage {
+		base, ok := new(big.Int).SetString(v.Slot, 10)
+		if !ok {
+			continue
+		}
+		if name, found := l.name(v.Label, v.Type, base, s); found {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// name returns the name of the slot if it's occupied by the variable of the type stored at the base slot.
+func (l *storageLayout) name(label, typ string, base, slot *big.Int) (string, bool) {
+	t, err := l.storageType(typ)
+	if err != nil {
+		return "", false
+	}
+	offset := new(big.Int).Sub(slot, base)
+
+	switch t.Encoding {
+	case "inplace":
+		if offset.Sign() < 0 || offset.Cmp(t.slots()) >= 0 {
+			return "", false
+		}
+		for _, m := range t.Members {
+			memberSlot, ok := new(big.Int).SetString(m.Slot, 10)
+			if !ok {
+				continue
+			}
+			if name, found := l.name(label+"."+m.Label, m.Type, memberSlot.Add(memberSlot, base), slot); found {
+				return name, true
+			}
+		}
+		if t.Base != "" {
+			length, err := t.staticLength()
+			if err != nil {
+				return "", false
+			}
+			return l.elementName(label, t.Base, base, slot, length)
+		}
+		return label, true
+	case "bytes":
+		if offset.Sign() == 0 {
+			return label, true
+		}
+		data := dataSlot(base)
+		index := new(big.Int).Sub(slot, data)
+		if index.Sign() < 0 || index.Cmp(big.NewInt(maxDynamicLength)) >= 0 {
+			return "", false
+		}
+		return fmt.Sprintf("%s (data slot %s)", label, index), true
+	case "dynamic_array":
+		if offset.Sign() == 0 {
+			return label + ".length", true
+		}
+		return l.elementName(label, t.Base, dataSlot(base), slot, nil)
+	}
+	return "", false
+}
+
+// elementName returns the name of the slot if it's occupied by an element of an array
+// with the elements of the type starting at the base slot.
+// The length of static arrays limits the range of packed elements, it's nil for dynamic arrays.
+func (l *storageLayout) elementName(label, elementType string, base, slot, length *big.Int) (string, bool) {
+	t, found := l.Types[elementType]
+	if !found {
+		return "", false
+	}
+	offset := new(big.Int).Sub(slot, base)
+	if offset.Sign() < 0 {
+		return "", false
+	}
+
+	if size := t.size(); size < 32 && t.Encoding == "inplace" {
+		// elements smaller than a slot are packed
+		perSlot := int64(32 / size)
+		first := new(big.Int).Mul(offset, big.NewInt(perSlot))
+		if first.Cmp(big.NewInt(maxDynamicLength)) >= 0 {
+			return "", false
+		}
+		last := first.Int64() + perSlot - 1
+		if length != nil && length.IsInt64() && length.Int64()-1 < last {
+			last = length.Int64() - 1
+		}
+		return fmt.Sprintf("%s[%s-%d]", label, first, last), true
+	}
+
+	slots := t.slots()
+	index := new(big.Int).Div(offset, slots)
+	if index.Cmp(big.NewInt(maxDynamicLength)) >= 0 {
+		return "", false
+	}
+	elementBase := new(big.Int).Add(base, new(big.Int).Mul(index, slots))
+	return l.name(fmt.Sprintf("%s[%s]", label, index), elementType, elementBase, slot)
+}
+
+// dataSlot returns the first slot of the data of a dynamic array, bytes or string stored at the slot.
+func dataSlot(slot *big.Int) *big.Int {
+	return new(big.Int).SetBytes(crypto.Keccak256(common.BigToHash(slot).Bytes()))
+}
+
+// parseStorageLayout parses the storage layout of the contract if it was included in the combined-json.
+// Like the ABI, older versions of solc encode the storage layout as a JSON string.
+func (s *solcContract) parseStorageLayout() (*storageLayout, error) {
+	if len(s.StorageLayout) == 0 {
+		return nil, nil
+	}
+
+	raw := []byte(s.StorageLayout)
+	if strings.HasPrefix(string(raw), `"`) {
+		var str string
+		err := json.Unmarshal(s.StorageLayout, &str)
+		if err != nil {
+			return nil, err
+		}
+		raw = []byte(str)
+	}
+
+	layout := &storageLayout{}
+	err := json.Unmarshal(raw, layout)
+	if err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+// AddStorageLayout registers the storage layout of a contract generated by solc (the storageLayout output),
+// e.g. of a contract compiled without storage-layout in the combined-json, so it can be read with StorageReader
+// and its slots are named in state diffs. It replaces the layout included in the combined-json.
+func (t *TestRig) AddStorageLayout(contractName string, layout []byte) error {
+	parsed := &storageLayout{}
+	err := json.Unmarshal(layout, parsed)
+	if err != nil {
+		return fmt.Errorf("could not parse storage layout of %s: %s", contractName, err.Error())
+	}
+	t.storageLayouts[contractName] = parsed
+	return nil
+}
+
+// StorageReader reads state variables of a contract directly from its storage.
+type StorageReader struct {
+	be      TestBackend
+	address common.Address
+	layout  *storageLayout
+}
+
+// StorageReader returns a reader of the state variables of the contract deployed at the address,
+// so tests can assert the internal state of the contract without public getters.
+// The storage layout has to be included in the combined-json of the contract or added with AddStorageLayout.
+func (t *TestRig) StorageReader(be TestBackend, contractName string, address common.Address) (*StorageReader, error) {
+	layout := t.layoutOf(contractName)
+	if layout == nil {
+		return nil, fmt.Errorf("storage layout of %s is not known", contractName)
+	}
+	return &StorageReader{be: be, address: address, layout: layout}, nil
+}
+
+// layoutOf returns the storage layout of the contract, nil if it's not known.
+// A layout added with AddStorageLayout takes precedence over the one in the combined-json.
+func (t *TestRig) layoutOf(contractName string) *storageLayout {
+	if layout, found := t.storageLayouts[contractName]; found {
+		return layout
+	}
+	if c, found := t.contracts[contractName]; found {
+		return c.storage
+	}
+	return nil
+}
+
+// storageLocation is the position of a value in the storage.
+type storageLocation struct {
+	slot *big.Int
+	// offset is the number of bytes from the lower-order end of the slot.
+	offset int
+	typ    string
+}
+
+// Read reads the state variable with the name from the latest block.
+// Members of structs are selected with a dot, e.g. "config.limit".
+// Keys of mappings and indexes of arrays are given in the order they are needed to reach the value,
+// e.g. Read("allowances", owner, spender) or Read("users.balance", user) for a mapping of structs.
+//
+// Values are returned as the Go types used by abigen bindings (e.g. uint64, *big.Int, common.Address),
+// structs as map[string]interface{} and arrays as []interface{}.
+func (r *StorageReader) Read(variable string, keys ...interface{}) (interface{}, error) {
+	path := strings.Split(variable, ".")
+
+	var loc *storageLocation
+	for _, v := range r.layout.Storage {
+		if v.Label == path[0] {
+			slot, ok := new(big.Int).SetString(v.Slot, 10)
+			if !ok {
+				return nil, fmt.Errorf("invalid slot %q of %s", v.Slot, v.Label)
+			}
+			loc = &storageLocation{slot: slot, offset: v.Offset, typ: v.Type}
+		}
+	}
+	if loc == nil {
+		return nil, fmt.Errorf("unknown state variable %s", path[0])
+	}
+	name := path[0]
+	path = path[1:]
+
+	for {
+		t, err := r.layout.storageType(loc.typ)
+		if err != nil {
+			return nil, err
+		}
+		if t.Encoding == "mapping" {
+			if len(keys) == 0 {
+				return nil, fmt.Errorf("%s is a mapping, a key is needed to read it", name)
+			}
+			loc, err = r.layout.mappingValue(t, loc.slot, keys[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid key of %s: %s", name, err.Error())
+			}
+			name = fmt.Sprintf("%s[%s]", name, formatValue(keys[0]))
+			keys = keys[1:]
+			continue
+		}
+		if t.Base != "" && len(keys) > 0 {
+			index, ok := toBigInt(keys[0])
+			if !ok || index.Sign() < 0 {
+				return nil, fmt.Errorf("invalid index %v of %s", keys[0], name)
+			}
+			base, length, err := r.array(t, loc)
+			if err != nil {
+				return nil, err
+			}
+			if index.Cmp(length) >= 0 {
+				return nil, fmt.Errorf("index %s of %s out of bounds, length is %s", index, name, length)
+			}
+			loc, err = r.layout.element(t.Base, base, index)
+			if err != nil {
+				return nil, err
+			}
+			name = fmt.Sprintf("%s[%s]", name, index)
+			keys = keys[1:]
+			continue
+		}
+		if len(t.Members) > 0 && len(path) > 0 {
+			member, err := t.member(loc.slot, path[0])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err.Error())
+			}
+			loc = member
+			name = name + "." + path[0]
+			path = path[1:]
+			continue
+		}
+		break
+	}
+
+	if len(path) > 0 {
+		return nil, fmt.Errorf("%s has no member %s", name, path[0])
+	}
+	if len(keys) > 0 {
+		return nil, fmt.Errorf("too many keys to read %s", name)
+	}
+	return r.read(loc)
+}
+
+// read reads the whole value at the location.
+func (r *StorageReader) read(loc *storageLocation) (interface{}, error) {
+	t, err := r.layout.storageType(loc.typ)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case t.Encoding == "mapping":
+		return nil, fmt.Errorf("can't read %s without a key", t.Label)
+	case t.Encoding == "bytes":
+		return r.readBytes(t, loc.slot)
+	case t.Base != "":
+		base, length, err := r.array(t, loc)
+		if err != nil {
+			return nil, err
+		}
+		if !length.IsInt64() || length.Int64() > maxReadLength {
+			return nil, fmt.Errorf("can't read %s with length %s, at most %d elements are read as a whole", t.Label, length, maxReadLength)
+		}
+		values := make([]interface{}, length.Int64())
+		for i := range values {
+			element, err := r.layout.element(t.Base, base, big.NewInt(int64(i)))
+			if err != nil {
+				return nil, err
+			}
+			values[i], err = r.read(element)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	case len(t.Members) > 0:
+		values := map[string]interface{}{}
+		for _, m := range t.Members {
+			member, err := t.member(loc.slot, m.Label)
+			if err != nil {
+				return nil, err
+			}
+			values[m.Label], err = r.read(member)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+
+	if size := t.size(); loc.offset < 0 || size < 0 || loc.offset+size > 32 {
+		return nil, fmt.Errorf("invalid storage layout: %s with %d bytes at offset %d exceeds the slot", t.Label, size, loc.offset)
+	}
+	word, err := r.slot(loc.slot)
+	if err != nil {
+		return nil, err
+	}
+	return t.decode(word[32-loc.offset-t.size() : 32-loc.offset])
+}
+
+// array returns the first slot of the elements and the length of the array at the location.
+func (r *StorageReader) array(t storageType, loc *storageLocation) (*big.Int, *big.Int, error) {
+	if t.Encoding == "dynamic_array" {
+		length, err := r.slot(loc.slot)
+		if err != nil {
+			return nil, nil, err
+		}
+		return dataSlot(loc.slot), new(big.Int).SetBytes(length), nil
+	}
+	length, err := t.staticLength()
+	if err != nil {
+		return nil, nil, err
+	}
+	return loc.slot, length, nil
+}
+
+// readBytes reads bytes or a string, stored in the slot if shorter than 32 bytes, or in the data slots otherwise.
+func (r *StorageReader) readBytes(t storageType, slot *big.Int) (interface{}, error) {
+	word, err := r.slot(slot)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if word[31]&1 == 0 {
+		// values shorter than 32 bytes are stored with twice their length in the last byte
+		length := word[31] / 2
+		if length > 31 {
+			return nil, fmt.Errorf("invalid length %d of %s stored in the slot", length, t.Label)
+		}
+		data = word[:length]
+	} else {
+		length := new(big.Int).SetBytes(word)
+		length.Rsh(length, 1)
+		if !length.IsInt64() || length.Int64() > maxReadLength {
+			return nil, fmt.Errorf("can't read %s with length %s, at most %d bytes are read as a whole", t.Label, length, maxReadLength)
+		}
+		data = make([]byte, 0, length.Int64())
+		for s := dataSlot(slot); int64(len(data)) < length.Int64(); s.Add(s, big.NewInt(1)) {
+			word, err := r.slot(s)
+			if err != nil {
+				return nil, err
+			}
+			if remaining := int(length.Int64()) - len(data); remaining < 32 {
+				word = word[:remaining]
+			}
+			data = append(data, word...)
+		}
+	}
+
+	if t.Label == "string" {
+		return string(data), nil
+	}
+	return data, nil
+}
+
+func (r *StorageReader) slot(slot *big.Int) ([]byte, error) {
+	value, err := r.be.StorageAt(context.Background(), r.address, common.BigToHash(slot), nil)
+	if err != nil {
+		return nil, err
+	}
+	return common.LeftPadBytes(value, 32), nil
+}
+
+func (l *storageLayout) storageType(id string) (storageType, error) {
+	t, found := l.Types[id]
+	if !found {
+		return storageType{}, fmt.Errorf("unknown storage type %s", id)
+	}
+	t.id = id
+	return t, nil
+}
+
+// mappingValue returns the location of the value of the mapping at the slot with the key.
+func (l *storageLayout) mappingValue(t storageType, slot *big.Int, key interface{}) (*storageLocation, error) {
+	keyType, err := l.storageType(t.Key)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := keyType.encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	hash := crypto.Keccak256(encoded, common.BigToHash(slot).Bytes())
+	return &storageLocation{slot: new(big.Int).SetBytes(hash), typ: t.Value}, nil
+}
+
+// element returns the location of the element with the index of an array with the elements starting at the base slot.
+func (l *storageLayout) element(elementType string, base, index *big.Int) (*storageLocation, error) {
+	t, err := l.storageType(elementType)
+	if err != nil {
+		return nil, err
+	}
+	if size := t.size(); size < 32 && t.Encoding == "inplace" {
+		// elements smaller than a slot are packed
+		perSlot := big.NewInt(int64(32 / size))
+		slot, position := new(big.Int).DivMod(index, perSlot, new(big.Int))
+		return &storageLocation{slot: slot.Add(slot, base), offset: int(position.Int64()) * size, typ: elementType}, nil
+	}
+	slot := new(big.Int).Mul(index, t.slots())
+	return &storageLocation{slot: slot.Add(slot, base), typ: elementType}, nil
+}
+
+// member returns the location of the member of the struct stored at the slot.
+func (t storageType) member(slot *big.Int, name string) (*storageLocation, error) {
+	for _, m := range t.Members {
+		if m.Label != name {
+			continue
+		}
+		memberSlot, ok := new(big.Int).SetString(m.Slot, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid slot %q of %s", m.Slot, m.Label)
+		}
+		return &storageLocation{slot: memberSlot.Add(memberSlot, slot), offset: m.Offset, typ: m.Type}, nil
+	}
+	return nil, fmt.Errorf("%s has no member %s", t.Label, name)
+}
+
+// staticLength returns the length of a static array from its type id, e.g. t_array(t_uint256)3_storage.
+func (t storageType) staticLength() (*big.Int, error) {
+	i := strings.LastIndex(t.id, ")")
+	if i == -1 {
+		return nil, fmt.Errorf("unknown length of %s", t.Label)
+	}
+	length, ok := new(big.Int).SetString(strings.TrimSuffix(t.id[i+1:], "_storage"), 10)
+	if !ok {
+		return nil, fmt.Errorf("unknown length of %s", t.Label)
+	}
+	return length, nil
+}
+
+// abiType returns the ABI type of a value type.
+func (t storageType) abiType() (abi.Type, error) {
+	label := t.Label
+	switch {
+	case strings.HasPrefix(label, "address") || strings.HasPrefix(label, "contract "):
+		label = "address"
+	case strings.HasPrefix(label, "enum "):
+		label = fmt.Sprintf("uint%d", 8*t.size())
+	}
+	return abi.NewType(label, "", nil)
+}
+
+// decode decodes a value type from the bytes it occupies in its slot.
+func (t storageType) decode(data []byte) (interface{}, error) {
+	typ, err := t.abiType()
+	if err != nil {
+		return nil, fmt.Errorf("can't decode %s: %s", t.Label, err.Error())
+	}
+
+	word := make([]byte, 32)
+	switch typ.T {
+	case abi.FixedBytesTy:
+		copy(word, data)
+	case abi.IntTy:
+		if len(data) > 0 && data[0]&0x80 != 0 {
+			for i := range word {
+				word[i] = 0xff
+			}
+		}
+		copy(word[32-len(data):], data)
+	default:
+		copy(word[32-len(data):], data)
+	}
+
+	values, err := abi.Arguments{{Type: typ}}.Unpack(word)
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+// encodeKey encodes a mapping key of the type the way solidity does before hashing it with the slot of the mapping.
+func (t storageType) encodeKey(key interface{}) ([]byte, error) {
+	if t.Encoding == "bytes" {
+		switch k := key.(type) {
+		case string:
+			return []byte(k), nil
+		case []byte:
+			return k, nil
+		}
+		return nil, fmt.Errorf("%v is not a %s", key, t.Label)
+	}
+
+	typ, err := t.abiType()
+	if err != nil {
+		return nil, err
+	}
+	switch typ.T {
+	case abi.IntTy, abi.UintTy:
+		k, ok := toBigInt(key)
+		if !ok {
+			return nil, fmt.Errorf("%v is not an integer", key)
+		}
+		return math.U256Bytes(new(big.Int).Set(k)), nil
+	case abi.AddressTy:
+		k, ok := key.(common.Address)
+		if !ok {
+			return nil, fmt.Errorf("%v is not an address", key)
+		}
+		return common.LeftPadBytes(k.Bytes(), 32), nil
+	case abi.BoolTy:
+		k, ok := key.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a bool", key)
+		}
+		if k {
+			return common.LeftPadBytes([]byte{1}, 32), nil
+		}
+		return make([]byte, 32), nil
+	case abi.FixedBytesTy:
+		word := make([]byte, 32)
+		if k, ok := key.([]byte); ok && len(k) == typ.Size {
+			copy(word, k)
+			return word, nil
+		}
+		if rv := reflect.ValueOf(key); rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 && rv.Len() == typ.Size {
+			reflect.Copy(reflect.ValueOf(word), rv)
+			return word, nil
+		}
+		return nil, fmt.Errorf("%v is not a %s", key, t.Label)
+	}
+	return nil, fmt.Errorf("unsupported key type %s", t.Label)
+}
@@ -0,0 +1,226 @@
+package ethertest_test
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+	"github.com/tokencard/ethertest"
+)
+
+// walletLayout is the storage layout solc generates for:
+//
+//	contract Wallet {
+//	  struct Config { uint128 max; uint128 min; string name; }
+//	  address owner;
+//	  bool locked;
+//	  uint64 nonce;
+//	  uint256 limit;
+//	  mapping(address => uint256) balances;
+//	  mapping(address => mapping(address => uint256)) allowances;
+//	  uint256[] values;
+//	  uint16[3] small;
+//	  Config config;
+//	  mapping(uint256 => Config) configs;
+//	  string name;
+//	  int8 delta;
+//	}
+const walletLayout = `{
+  "storage": [
+    {"astId": 10, "contract": "wallet.sol:Wallet", "label": "owner", "offset": 0, "slot": "0", "type": "t_address"},
+    {"astId": 12, "contract": "wallet.sol:Wallet", "label": "locked", "offset": 20, "slot": "0", "type": "t_bool"},
+    {"astId": 14, "contract": "wallet.sol:Wallet", "label": "nonce", "offset": 21, "slot": "0", "type": "t_uint64"},
+    {"astId": 16, "contract": "wallet.sol:Wallet", "label": "limit", "offset": 0, "slot": "1", "type": "t_uint256"},
+    {"astId": 20, "contract": "wallet.sol:Wallet", "label": "balances", "offset": 0, "slot": "2", "type": "t_mapping(t_address,t_uint256)"},
+    {"astId": 26, "contract": "wallet.sol:Wallet", "label": "allowances", "offset": 0, "slot": "3", "type": "t_mapping(t_address,t_mapping(t_address,t_uint256))"},
+    {"astId": 29, "contract": "wallet.sol:Wallet", "label": "values", "offset": 0, "slot": "4", "type": "t_array(t_uint256)dyn_storage"},
+    {"astId": 33, "contract": "wallet.sol:Wallet", "label": "small", "offset": 0, "slot": "5", "type": "t_array(t_uint16)3_storage"},
+    {"astId": 35, "contract": "wallet.sol:Wallet", "label": "config", "offset": 0, "slot": "6", "type": "t_struct(Config)8_storage"},
+    {"astId": 39, "contract": "wallet.sol:Wallet", "label": "configs", "offset": 0, "slot": "8", "type": "t_mapping(t_uint256,t_struct(Config)8_storage)"},
+    {"astId": 41, "contract": "wallet.sol:Wallet", "label": "name", "offset": 0, "slot": "9", "type": "t_string_storage"},
+    {"astId": 43, "contract": "wallet.sol:Wallet", "label": "delta", "offset": 0, "slot": "10", "type": "t_int8"}
+  ],
+  "types": {
+    "t_address": {"encoding": "inplace", "label": "address", "numberOfBytes": "20"},
+    "t_array(t_uint16)3_storage": {"base": "t_uint16", "encoding": "inplace", "label": "uint16[3]", "numberOfBytes": "32"},
+    "t_array(t_uint256)dyn_storage": {"base": "t_uint256", "encoding": "dynamic_array", "label": "uint256[]", "numberOfBytes": "32"},
+    "t_bool": {"encoding": "inplace", "label": "bool", "numberOfBytes": "1"},
+    "t_int8": {"encoding": "inplace", "label": "int8", "numberOfBytes": "1"},
+    "t_mapping(t_address,t_mapping(t_address,t_uint256))": {"encoding": "mapping", "key": "t_address", "label": "mapping(address => mapping(address => uint256))", "numberOfBytes": "32", "value": "t_mapping(t_address,t_uint256)"},
+    "t_mapping(t_address,t_uint256)": {"encoding": "mapping", "key": "t_address", "label": "mapping(address => uint256)", "numberOfBytes": "32", "value": "t_uint256"},
+    "t_mapping(t_uint256,t_struct(Config)8_storage)": {"encoding": "mapping", "key": "t_uint256", "label": "mapping(uint256 => struct Wallet.Config)", "numberOfBytes": "32", "value": "t_struct(Config)8_storage"},
+    "t_string_storage": {"encoding": "bytes", "label": "string", "numberOfBytes": "32"},
+    "t_struct(Config)8_storage": {"encoding": "inplace", "label": "struct Wallet.Config", "members": [
+      {"astId": 3, "contract": "wallet.sol:Wallet", "label": "max", "offset": 0, "slot": "0", "type": "t_uint128"},
+      {"astId": 5, "contract": "wallet.sol:Wallet", "label": "min", "offset": 16, "slot": "0", "type": "t_uint128"},
+      {"astId": 7, "contract": "wallet.sol:Wallet", "label": "name", "offset": 0, "slot": "1", "type": "t_string_storage"}
+    ], "numberOfBytes": "64"},
+    "t_uint128": {"encoding": "inplace", "label": "uint128", "numberOfBytes": "16"},
+    "t_uint16": {"encoding": "inplace", "label": "uint16", "numberOfBytes": "2"},
+    "t_uint256": {"encoding": "inplace", "label": "uint256", "numberOfBytes": "32"},
+    "t_uint64": {"encoding": "inplace", "label": "uint64", "numberOfBytes": "8"}
+  }
+}`
+
+func TestStorageReader(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var spender = ethertest.NewAccount()
+	var wallet = common.HexToAddress("0xa11e7")
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	require.Nil(tr.AddStorageLayout("wallet.sol:Wallet", []byte(walletLayout)))
+	require.NotNil(tr.AddStorageLayout("wallet.sol:Invalid", []byte("{")))
+
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	slot := func(n int64) common.Hash {
+		return common.BigToHash(big.NewInt(n))
+	}
+	offset := func(h common.Hash, n int64) common.Hash {
+		return common.BigToHash(new(big.Int).Add(h.Big(), big.NewInt(n)))
+	}
+	mappingSlot := func(key []byte, slot common.Hash) common.Hash {
+		return crypto.Keccak256Hash(common.LeftPadBytes(key, 32), slot.Bytes())
+	}
+	word := func(hex string) common.Hash {
+		return common.HexToHash(hex)
+	}
+
+	storage := map[common.Hash]common.Hash{
+		// nonce 7, locked, owner
+		slot(0): word("0x000000000000000000000701" + owner.Address().Hex()[2:]),
+		slot(1): slot(1000),
+		mappingSlot(owner.Address().Bytes(), slot(2)):                                         slot(50),
+		mappingSlot(spender.Address().Bytes(), mappingSlot(owner.Address().Bytes(), slot(3))): slot(20),
+		slot(4): slot(2),
+		offset(crypto.Keccak256Hash(slot(4).Bytes()), 1): slot(42),
+		// small = [1, 2, 3]
+		slot(5): word("0x000300020001"),
+		// config = Config(5, 1, "main")
+		slot(6): word("0x0000000000000000000000000000000100000000000000000000000000000005"),
+		slot(7): word("0x6d61696e00000000000000000000000000000000000000000000000000000008"),
+		offset(mappingSlot(big.NewInt(9).Bytes(), slot(8)), 1): word("0x6261636b7570000000000000000000000000000000000000000000000000000c"),
+		// name is 41 bytes long
+		slot(9):                               slot(83),
+		crypto.Keccak256Hash(slot(9).Bytes()): word("0x6120766572792076657279206c6f6e672077616c6c6574206e616d6520746861"),
+		offset(crypto.Keccak256Hash(slot(9).Bytes()), 1): word("0x7420697320757365640000000000000000000000000000000000000000000000"),
+		// delta = -2
+		slot(10): word("0xfe"),
+	}
+	// accounts without code, nonce and balance are empty and removed with their storage
+	require.Nil(be.SetCode(wallet, []byte{0x00}))
+	for k, v := range storage {
+		require.Nil(be.SetStorageAt(wallet, k, v))
+	}
+	require.Nil(be.Commit())
+
+	reader, err := tr.StorageReader(be, "wallet.sol:Wallet", wallet)
+	require.Nil(err)
+
+	read := func(variable string, keys ...interface{}) interface{} {
+		value, err := reader.Read(variable, keys...)
+		require.Nil(err)
+		return value
+	}
+
+	require.Equal(owner.Address(), read("owner"))
+	require.Equal(true, read("locked"))
+	require.Equal(uint64(7), read("nonce"))
+	require.Equal(big.NewInt(1000), read("limit"))
+	require.Equal(big.NewInt(50), read("balances", owner.Address()))
+	require.Equal("0", read("balances", spender.Address()).(*big.Int).String())
+	require.Equal(big.NewInt(20), read("allowances", owner.Address(), spender.Address()))
+	require.Equal("[0 42]", fmt.Sprint(read("values")))
+	require.Equal(big.NewInt(42), read("values", 1))
+	require.Equal([]interface{}{uint16(1), uint16(2), uint16(3)}, read("small"))
+	require.Equal(uint16(3), read("small", 2))
+	require.Equal(map[string]interface{}{"max": big.NewInt(5), "min": big.NewInt(1), "name": "main"}, read("config"))
+	require.Equal(big.NewInt(1), read("config.min"))
+	require.Equal("backup", read("configs.name", 9))
+	require.Equal("0", read("configs.max", big.NewInt(9)).(*big.Int).String())
+	require.Equal("a very very long wallet name that is used", read("name"))
+	require.Equal(int8(-2), read("delta"))
+
+	_, err = reader.Read("unknown")
+	require.EqualError(err, "unknown state variable unknown")
+	_, err = reader.Read("balances")
+	require.EqualError(err, "balances is a mapping, a key is needed to read it")
+	_, err = reader.Read("balances", 1)
+	require.EqualError(err, "invalid key of balances: 1 is not an address")
+	_, err = reader.Read("values", 2)
+	require.EqualError(err, "index 2 of values out of bounds, length is 2")
+	_, err = reader.Read("config.unknown")
+	require.EqualError(err, "config: struct Wallet.Config has no member unknown")
+	_, err = reader.Read("limit.value")
+	require.EqualError(err, "limit has no member value")
+	_, err = reader.Read("limit", 1)
+	require.EqualError(err, "too many keys to read limit")
+
+	_, err = tr.StorageReader(be, "wallet.sol:Unknown", wallet)
+	require.NotNil(err)
+
+	c, _, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+	reader, err = tr.StorageReader(be, "test.sol:Test", c.Address())
+	require.Nil(err)
+	require.Equal("initial value", read("value"))
+}
+
+func TestStorageReaderWithInvalidData(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var account = common.HexToAddress("0xbad")
+
+	require := require.New(t)
+	require.Nil(tr.AddStorageLayout("invalid.sol:Invalid", []byte(`{
+  "storage": [
+    {"astId": 1, "contract": "invalid.sol:Invalid", "label": "name", "offset": 0, "slot": "0", "type": "t_string_storage"},
+    {"astId": 2, "contract": "invalid.sol:Invalid", "label": "wide", "offset": 0, "slot": "1", "type": "t_uint320"},
+    {"astId": 3, "contract": "invalid.sol:Invalid", "label": "shifted", "offset": 30, "slot": "2", "type": "t_uint64"},
+    {"astId": 4, "contract": "invalid.sol:Invalid", "label": "data", "offset": 0, "slot": "3", "type": "t_bytes_storage"},
+    {"astId": 5, "contract": "invalid.sol:Invalid", "label": "values", "offset": 0, "slot": "4", "type": "t_array(t_uint256)dyn_storage"}
+  ],
+  "types": {
+    "t_array(t_uint256)dyn_storage": {"base": "t_uint256", "encoding": "dynamic_array", "label": "uint256[]", "numberOfBytes": "32"},
+    "t_bytes_storage": {"encoding": "bytes", "label": "bytes", "numberOfBytes": "32"},
+    "t_string_storage": {"encoding": "bytes", "label": "string", "numberOfBytes": "32"},
+    "t_uint256": {"encoding": "inplace", "label": "uint256", "numberOfBytes": "32"},
+    "t_uint320": {"encoding": "inplace", "label": "uint320", "numberOfBytes": "40"},
+    "t_uint64": {"encoding": "inplace", "label": "uint64", "numberOfBytes": "8"}
+  }
+}`)))
+
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	// a short string can't be longer than 31 bytes
+	require.Nil(be.SetCode(account, []byte{0x00}))
+	require.Nil(be.SetStorageAt(account, common.Hash{}, common.HexToHash("0xfe")))
+	// huge lengths of long bytes and of dynamic arrays
+	require.Nil(be.SetStorageAt(account, common.BigToHash(big.NewInt(3)), common.HexToHash("0xffffffff")))
+	require.Nil(be.SetStorageAt(account, common.BigToHash(big.NewInt(4)), common.HexToHash("0xffffffff")))
+	require.Nil(be.Commit())
+
+	reader, err := tr.StorageReader(be, "invalid.sol:Invalid", account)
+	require.Nil(err)
+	_, err = reader.Read("name")
+	require.EqualError(err, "invalid length 127 of string stored in the slot")
+	_, err = reader.Read("wide")
+	require.EqualError(err, "invalid storage layout: uint320 with 40 bytes at offset 0 exceeds the slot")
+	_, err = reader.Read("shifted")
+	require.EqualError(err, "invalid storage layout: uint64 with 8 bytes at offset 30 exceeds the slot")
+	_, err = reader.Read("data")
+	require.EqualError(err, "can't read bytes with length 2147483647, at most 65536 bytes are read as a whole")
+	_, err = reader.Read("values")
+	require.EqualError(err, "can't read uint256[] with length 4294967295, at most 65536 elements are read as a whole")
+	_, err = reader.Read("values", 7)
+	require.Nil(err)
+}
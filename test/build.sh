@@ -6,11 +6,13 @@ SOLC="docker run --rm -u `id -u` -v $PWD:/solidity --workdir /solidity/contracts
 
 compile_solidity() {
   echo "compiling ${1}"
-  ${SOLC} --overwrite --bin --abi ${1}.sol -o /solidity/build/${1} --combined-json bin-runtime,srcmap-runtime,ast,srcmap,bin
+  ${SOLC} --overwrite --bin --abi ${1}.sol -o /solidity/build/${1} --combined-json abi,bin-runtime,srcmap-runtime,ast,srcmap,bin,storage-layout
 }
 
 contract_sources=(
   'test'
+  'linked'
+  'forwarder'
 )
 
 for c in "${contract_sources[@]}"
@@ -21,7 +23,7 @@ done
 
 
 # Generate Go bindings from solidity contracts.
-ABIGEN="docker run --rm -u `id -u` --workdir /contracts -e GOPATH=/go -v $PWD:/contracts ethereum/client-go:alltools-v1.9.12 abigen"
+ABIGEN="docker run --rm -u `id -u` --workdir /contracts -e GOPATH=/go -v $PWD:/contracts ethereum/client-go:alltools-v1.13.8 abigen"
 
 
 generate_binding() {
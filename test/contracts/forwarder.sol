@@ -0,0 +1,19 @@
+pragma solidity ^0.6.5;
+
+contract Forwarder {
+
+  address public target;
+
+  constructor(address _target) public {
+    target = _target;
+  }
+
+  fallback() external {
+    (bool success, bytes memory result) = target.call(msg.data);
+    if (!success) {
+      assembly { revert(add(result, 32), mload(result)) }
+    }
+    assembly { return(add(result, 32), mload(result)) }
+  }
+
+}
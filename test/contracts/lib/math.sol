@@ -0,0 +1,9 @@
+pragma solidity ^0.6.5;
+
+library Math {
+
+  function add(uint256 a, uint256 b) public pure returns (uint256) {
+    return a + b;
+  }
+
+}
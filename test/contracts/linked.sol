@@ -0,0 +1,13 @@
+pragma solidity ^0.6.5;
+
+import "./lib/math.sol";
+
+contract Linked {
+
+  uint256 public total;
+
+  function add(uint256 x) external {
+    total = Math.add(total, x);
+  }
+
+}
@@ -3,6 +3,7 @@ package ethertest
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -11,13 +12,16 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
 	"github.com/olekukonko/tablewriter"
 	"github.com/tokencard/ethertest/backends"
 	"github.com/tokencard/ethertest/stats"
@@ -25,19 +29,27 @@ import (
 
 // TestRig ...
 type TestRig struct {
-	genesisAlloc core.GenesisAlloc
-	contracts    map[string]*contract
-	coverage     map[string]*sourceCodeCoverage
-	tracer       *tracer
+	genesisAlloc   core.GenesisAlloc
+	contracts      map[string]*contract
+	abis           []abi.ABI
+	storageLayouts map[string]*storageLayout
+	coverage       map[string]*sourceCodeCoverage
+	tracer         *tracer
+	// traceReverts is set when failed transactions are re-executed traced for the location of the revert.
+	traceReverts bool
+	// mu is held while an execution is traced, backends can execute concurrently.
+	// It guards the trace, the coverage and the addresses of the contracts.
+	mu sync.Mutex
 }
 
 // NewTestRig creates a new instance of a test rig
 func NewTestRig() *TestRig {
 	return &TestRig{
-		genesisAlloc: core.GenesisAlloc{},
-		contracts:    map[string]*contract{},
-		coverage:     map[string]*sourceCodeCoverage{},
-		tracer:       newTracer(),
+		genesisAlloc:   core.GenesisAlloc{},
+		contracts:      map[string]*contract{},
+		coverage:       map[string]*sourceCodeCoverage{},
+		tracer:         newTracer(),
+		storageLayouts: map[string]*storageLayout{},
 	}
 }
 
@@ -45,47 +57,289 @@ func NewTestRig() *TestRig {
 type TestBackend interface {
 	bind.ContractBackend
 	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error)
 	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
-	Commit()
+	TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error)
+	TransactionSender(tx *types.Transaction) (common.Address, error)
+	TransactionError(ctx context.Context, txHash common.Hash) error
+	TraceTransactionError(ctx context.Context, txHash common.Hash, tracer vm.EVMLogger) error
+	TraceTransaction(ctx context.Context, txHash common.Hash, config *logger.Config) (json.RawMessage, error)
+	TraceCalls(ctx context.Context, txHash common.Hash) (*backends.CallFrame, error)
+	StateDiff(ctx context.Context, txHash common.Hash) (backends.StateDiff, error)
+	Commit() error
 	Rollback()
+	Snapshot() int
+	RevertTo(id int) error
+	Fork() (TestBackend, error)
 	AdjustTime(adjustment time.Duration) error
+	SetNextBlockTimestamp(t time.Time) error
+	WarpTo(t time.Time) error
+	MineBlocks(n int) error
+	MiningError() error
+	SetBlockInterval(interval time.Duration) error
+	Impersonate(address common.Address)
+	StopImpersonating(address common.Address)
+	SetBalance(address common.Address, balance *big.Int) error
+	SetNonce(address common.Address, nonce uint64) error
+	SetCode(address common.Address, code []byte) error
+	SetStorageAt(address common.Address, key, value common.Hash) error
 	Close() error
 	Blockchain() *core.BlockChain
 }
 
+// Errors returned by TestBackend for transactions a transaction pool of a node would reject.
+var (
+	ErrInvalidSender      = backends.ErrInvalidSender
+	ErrNonceTooLow        = backends.ErrNonceTooLow
+	ErrNonceTooHigh       = backends.ErrNonceTooHigh
+	ErrInsufficientFunds  = backends.ErrInsufficientFunds
+	ErrIntrinsicGas       = backends.ErrIntrinsicGas
+	ErrGasLimitExceeded   = backends.ErrGasLimitExceeded
+	ErrNegativeValue      = backends.ErrNegativeValue
+	ErrAlreadyKnown       = backends.ErrAlreadyKnown
+	ErrReplaceUnderpriced = backends.ErrReplaceUnderpriced
+	ErrTxTypeNotSupported = backends.ErrTxTypeNotSupported
+	ErrTipAboveFeeCap     = backends.ErrTipAboveFeeCap
+	ErrFeeCapTooLow       = backends.ErrFeeCapTooLow
+	ErrSenderNoEOA        = backends.ErrSenderNoEOA
+)
+
+// ErrNotMined is returned by SendTransaction of a TestBackend created WithAutomine(true)
+// when the transaction was accepted but the block including it could not be mined.
+// The transaction stays pending until it is mined by Commit or discarded by Rollback.
+var ErrNotMined = errors.New("transaction accepted but not mined")
+
 type interceptingBackend struct {
-	TestBackend
+	*backends.SimulatedBackend
+	mu               sync.Mutex
 	sentTransactions []*types.Transaction
+	recorded         *[]*types.Transaction // Transactions sent while recording, nil if not recording
 	tr               *TestRig
+	libraries        map[string]common.Address
+	snapshots        map[int]map[string]common.Address
+	automine         bool
+	miningInterval   time.Duration
+	stopMining       chan struct{}
+	miningStopped    chan struct{}
+	miningErr        error
+}
+
+func newInterceptingBackend(sb *backends.SimulatedBackend, t *TestRig, automine bool, miningInterval time.Duration) *interceptingBackend {
+	ib := &interceptingBackend{
+		SimulatedBackend: sb,
+		tr:               t,
+		libraries:        map[string]common.Address{},
+		snapshots:        map[int]map[string]common.Address{},
+		automine:         automine,
+		miningInterval:   miningInterval,
+	}
+	if miningInterval > 0 {
+		ib.stopMining = make(chan struct{})
+		ib.miningStopped = make(chan struct{})
+		go ib.mine()
+	}
+	return ib
+}
+
+// mine commits a new block every mining interval until the backend is closed.
+func (ib *interceptingBackend) mine() {
+	defer close(ib.miningStopped)
+	ticker := time.NewTicker(ib.miningInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// a failed block will be retried on the next tick
+			err := ib.Commit()
+			ib.mu.Lock()
+			ib.miningErr = err
+			ib.mu.Unlock()
+		case <-ib.stopMining:
+			return
+		}
+	}
+}
+
+// MiningError returns the error of the last block mined by the mining interval,
+// nil if it was mined successfully.
+func (ib *interceptingBackend) MiningError() error {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	return ib.miningErr
+}
+
+func (ib *interceptingBackend) Close() error {
+	if ib.stopMining != nil {
+		close(ib.stopMining)
+		<-ib.miningStopped
+		ib.stopMining = nil
+	}
+	return ib.SimulatedBackend.Close()
+}
+
+func (ib *interceptingBackend) Rollback() {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	ib.SimulatedBackend.Rollback()
+	ib.sentTransactions = nil
+}
+
+func (ib *interceptingBackend) Snapshot() int {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	id := ib.SimulatedBackend.Snapshot()
+	libraries := map[string]common.Address{}
+	for n, a := range ib.libraries {
+		libraries[n] = a
+	}
+	ib.snapshots[id] = libraries
+	return id
+}
+
+func (ib *interceptingBackend) RevertTo(id int) error {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	err := ib.SimulatedBackend.RevertTo(id)
+	if err != nil {
+		return err
+	}
+	ib.sentTransactions = nil
+	ib.libraries = map[string]common.Address{}
+	for n, a := range ib.snapshots[id] {
+		ib.libraries[n] = a
+	}
+	// snapshots taken after the reverted one are invalidated,
+	// the reverted one can be reverted to again
+	for sid := range ib.snapshots {
+		if sid > id {
+			delete(ib.snapshots, sid)
+		}
+	}
+	return nil
 }
 
-func (ib *interceptingBackend) Commit() {
-	ib.TestBackend.Commit()
+func (ib *interceptingBackend) Commit() error {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	return ib.commit()
+}
+
+func (ib *interceptingBackend) commit() error {
+	err := ib.SimulatedBackend.Commit()
+	if err != nil {
+		return err
+	}
 
+	pending := []*types.Transaction{}
 	for _, t := range ib.sentTransactions {
 		r, err := ib.TransactionReceipt(context.Background(), t.Hash())
 		if err != nil {
-			panic(err)
+			return err
+		}
+
+		if r == nil {
+			// keep transactions that will be mined in one of the following blocks
+			_, isPending, _ := ib.TransactionByHash(context.Background(), t.Hash())
+			if isPending {
+				pending = append(pending, t)
+			}
+			continue
 		}
 
+		ib.tr.mu.Lock()
 		for _, c := range ib.tr.contracts {
 			to := t.To()
 			if to != nil {
 				c.transactionCommited(*to, t.Data(), r.GasUsed)
 			}
 		}
+		ib.tr.mu.Unlock()
 
 	}
-	ib.sentTransactions = nil
+	ib.sentTransactions = pending
+	return nil
+}
+
+// record returns the transactions sent while f is executed.
+func (ib *interceptingBackend) record(f func() error) ([]*types.Transaction, error) {
+	recorded := []*types.Transaction{}
+	ib.mu.Lock()
+	ib.recorded = &recorded
+	ib.mu.Unlock()
+
+	err := f()
+
+	ib.mu.Lock()
+	ib.recorded = nil
+	ib.mu.Unlock()
+	return recorded, err
+}
+
+func (ib *interceptingBackend) WarpTo(t time.Time) error {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	if err := ib.SimulatedBackend.SetNextBlockTimestamp(t); err != nil {
+		return err
+	}
+	return ib.commit()
+}
+
+func (ib *interceptingBackend) MineBlocks(n int) error {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		if err := ib.commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ib *interceptingBackend) Fork() (TestBackend, error) {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	sb, err := ib.SimulatedBackend.Fork()
+	if err != nil {
+		return nil, err
+	}
+
+	fork := newInterceptingBackend(sb, ib.tr, ib.automine, ib.miningInterval)
+	for n, a := range ib.libraries {
+		fork.libraries[n] = a
+	}
+	for id, libraries := range ib.snapshots {
+		fork.snapshots[id] = libraries
+	}
+	return fork, nil
 }
 
 func (ib *interceptingBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
 
-	err := ib.TestBackend.SendTransaction(ctx, tx)
+	err := ib.SimulatedBackend.SendTransaction(ctx, tx)
 	if err != nil {
 		return err
 	}
 	ib.sentTransactions = append(ib.sentTransactions, tx)
+	if ib.recorded != nil {
+		*ib.recorded = append(*ib.recorded, tx)
+	}
+
+	if ib.automine {
+		if err := ib.commit(); err != nil {
+			return fmt.Errorf("%w: %v", ErrNotMined, err)
+		}
+	}
 	return nil
 }
 
@@ -94,13 +348,18 @@ type backendOption func(*backendOptions)
 type backendOptions struct {
 	blockchainTime time.Time
 	blockGasLimit  uint64
+	automine       bool
+	miningInterval time.Duration
+	hardfork       Hardfork
+	forkBlocks     map[Hardfork]uint64
+	forkTimes      map[Hardfork]uint64
+	chainID        *big.Int
 }
 
 // WithBlockchainTime sets the initial time on the blockchain.
 // If not set, it will default to 1970-01-01T00:00:00Z
-// Warning: every commit() will increase the time by 15 seconds.
-// Once the blockchain time takes over the current time,
-// simulated backend will not accept new blocks.
+// Every commit() will increase the time by 10 seconds, unless changed with SetBlockInterval.
+// The blockchain time doesn't depend on the wall clock, it can be set to any time in the future.
 func WithBlockchainTime(t time.Time) func(*backendOptions) {
 	return func(opt *backendOptions) {
 		opt.blockchainTime = t
@@ -115,28 +374,52 @@ func WithBlockGasLimit(limit uint64) func(*backendOptions) {
 	}
 }
 
+// WithAutomine enables mining a new block for every transaction sent to the backend,
+// so Commit() doesn't have to be called after each transaction.
+// If not set, it will default to false.
+func WithAutomine(automine bool) func(*backendOptions) {
+	return func(opt *backendOptions) {
+		opt.automine = automine
+	}
+}
+
+// WithMiningInterval enables mining a new block every interval of the wall clock time
+// until the backend is closed.
+// If not set, blocks are only mined on Commit().
+func WithMiningInterval(interval time.Duration) func(*backendOptions) {
+	return func(opt *backendOptions) {
+		opt.miningInterval = interval
+	}
+}
+
 // NewTestBackend creates a new instance of TestBackend
 func (t *TestRig) NewTestBackend(opts ...backendOption) TestBackend {
 
 	backendOptions := &backendOptions{
 		blockGasLimit:  7981579,
 		blockchainTime: time.Unix(0, 0),
+		hardfork:       London,
+		forkBlocks:     map[Hardfork]uint64{},
+		forkTimes:      map[Hardfork]uint64{},
 	}
 	for _, opt := range opts {
 		opt(backendOptions)
 	}
 
-	sb := backends.NewSimulatedBackend(t.genesisAlloc, backendOptions.blockGasLimit, vm.Config{
-		Debug:  true,
+	config, err := backendOptions.chainConfig()
+	if err != nil {
+		panic(err)
+	}
+
+	sb := backends.NewSimulatedBackend(t.genesisAlloc, backendOptions.blockGasLimit, config, vm.Config{
 		Tracer: t,
 	}, backendOptions.blockchainTime)
 
+	t.mu.Lock()
 	t.tracer.reset()
+	t.mu.Unlock()
 
-	return &interceptingBackend{
-		TestBackend: sb,
-		tr:          t,
-	}
+	return newInterceptingBackend(sb, t, backendOptions.automine, backendOptions.miningInterval)
 }
 
 // AddGenesisAccountAllocation adds a GenesisAccount allocation to the test rig.
@@ -146,6 +429,42 @@ func (t *TestRig) AddGenesisAccountAllocation(a common.Address, balance *big.Int
 	return t
 }
 
+// AddGenesisAllocation adds a GenesisAccount allocation with balance, nonce, code and storage to the test rig,
+// e.g. to start with a mock contract or a pre-existing storage layout at a fixed address.
+// When a new TestBackend is created, current genesis account allocations are used.
+func (t *TestRig) AddGenesisAllocation(a common.Address, account core.GenesisAccount) *TestRig {
+	if account.Balance == nil {
+		account.Balance = new(big.Int)
+	}
+	t.genesisAlloc[a] = account
+	return t
+}
+
+// Accounts returns the first n accounts derived from DefaultMnemonic
+// and adds genesis allocations with the balance for them.
+// The accounts are the same in every run, so addresses in test output are reproducible.
+func (t *TestRig) Accounts(n int, balance *big.Int) []*Account {
+	accounts := make([]*Account, n)
+	for i := range accounts {
+		a, err := NewAccountFromMnemonic(DefaultMnemonic, fmt.Sprintf("m/44'/60'/0'/0/%d", i))
+		if err != nil {
+			panic(err)
+		}
+		t.AddGenesisAccountAllocation(a.Address(), balance)
+		accounts[i] = a
+	}
+	return accounts
+}
+
+// NamedAccount returns the account created from the name with NewAccountFromSeed
+// and adds a genesis allocation with the balance for it.
+// The same name always results in the same account.
+func (t *TestRig) NamedAccount(name string, balance *big.Int) *Account {
+	a := NewAccountFromSeed(name)
+	t.AddGenesisAccountAllocation(a.Address(), balance)
+	return a
+}
+
 func (t *TestRig) AddCoverageForContracts(combinedJSON string, contractsPath string) *TestRig {
 
 	f, err := os.Open(combinedJSON)
@@ -199,6 +518,11 @@ func (t *TestRig) AddCoverageForContracts(combinedJSON string, contractsPath str
 		}
 	}
 
+	libraries := []string{}
+	for cn := range sc.Contracts {
+		libraries = append(libraries, cn)
+	}
+
 	for n, s := range sourceCode {
 		sourceIndex := sc.findSourceIndex(n)
 		if sourceIndex < 0 {
@@ -207,7 +531,7 @@ func (t *TestRig) AddCoverageForContracts(combinedJSON string, contractsPath str
 		ss := sc.Sources[n]
 		for cn, scon := range sc.Contracts {
 			if scon.BinRuntime != "" && strings.HasPrefix(cn+":", n) {
-				con, err := newContract(cn, t.tracer, s, ss, scon, coverages)
+				con, err := newContract(cn, t.tracer, s, ss, scon, coverages, libraries)
 				if err != nil {
 					panic(err)
 				}
@@ -224,6 +548,9 @@ func (t *TestRig) PrintGasUsage(w io.Writer) {
 		return
 	}
 
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	for _, c := range t.contracts {
 
 		if !c.hasAnyGasInformation() {
@@ -259,6 +586,9 @@ func (t *TestRig) PrintGasUsage(w io.Writer) {
 }
 
 func (t *TestRig) CoverageOf(name string) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	c, found := t.coverage[name]
 	if !found {
 		keys := []string{}
@@ -277,6 +607,9 @@ func (t *TestRig) ExpectMinimumCoverage(name string, expectedCoverage float64) {
 		return
 	}
 
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	c, found := t.coverage[name]
 	if !found {
 		keys := []string{}
@@ -297,33 +630,71 @@ func (t *TestRig) ExpectMinimumCoverage(name string, expectedCoverage float64) {
 
 }
 
-func (t *TestRig) SaveTrace(w io.Writer) error {
-	return json.NewEncoder(w).Encode(t.tracer.trace)
+type traceOptions struct {
+	compress bool
+}
+
+// WithGzip compresses a saved trace with gzip, LoadTrace detects the compression.
+func WithGzip() func(*traceOptions) {
+	return func(o *traceOptions) {
+		o.compress = true
+	}
+}
+
+// SaveTrace writes the trace of all executions since the TestBackend was created,
+// see Trace for the format and LoadTrace to read it.
+func (t *TestRig) SaveTrace(w io.Writer, options ...func(*traceOptions)) error {
+	o := &traceOptions{}
+	for _, opt := range options {
+		opt(o)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.tracer.trace.Save(w, o.compress)
 }
 
 func (t *TestRig) LastExecuted() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	return t.tracer.trace.LastStep()
 }
 
-func (t *TestRig) CaptureStart(from common.Address, to common.Address, call bool, input []byte, gas uint64, value *big.Int) error {
-	return nil
+// CaptureTxStart locks the test rig until the execution ends.
+func (t *TestRig) CaptureTxStart(gasLimit uint64) {
+	t.mu.Lock()
+	t.tracer.executionStarted(gasLimit)
 }
 
-func (t *TestRig) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+func (t *TestRig) CaptureTxEnd(restGas uint64) {
+	t.tracer.executionEnded(restGas)
+	t.mu.Unlock()
+}
 
-	for _, c := range t.contracts {
-		c.executed(pc, contract.Address(), contract)
-	}
+func (t *TestRig) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.tracer.executionEntered(env.Context.BlockNumber.Uint64(), from, to, create, input, value)
+}
 
-	return nil
+func (t *TestRig) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	t.tracer.executionFailed(err)
 }
 
-func (t *TestRig) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
-	return nil
+func (t *TestRig) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
 }
 
-func (t *TestRig) CaptureEnd(output []byte, gasUsed uint64, tm time.Duration, err error) error {
-	return nil
+func (t *TestRig) CaptureExit(output []byte, gasUsed uint64, err error) {}
+
+func (t *TestRig) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+
+	for _, c := range t.contracts {
+		c.executed(pc, op, gas, scope.Contract.Address(), scope.Contract, depth)
+	}
+
+}
+
+func (t *TestRig) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
 }
 
 func shouldBeSilent() bool {
@@ -0,0 +1,721 @@
+package ethertest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+	"github.com/tokencard/ethertest"
+)
+
+func TestSnapshot(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	require.Nil(owner.Transfer(be, receiver.Address(), ethertest.EthToWei(1)))
+	snapshot := be.Snapshot()
+	head := be.Blockchain().CurrentBlock()
+
+	for i := 0; i < 2; i++ {
+		require.Nil(owner.Transfer(be, receiver.Address(), ethertest.EthToWei(1)))
+		require.Nil(owner.Transfer(be, receiver.Address(), ethertest.EthToWei(1)))
+		require.Equal(ethertest.EthToWei(3), receiver.Balance(be))
+
+		later := be.Snapshot()
+		require.Nil(be.RevertTo(snapshot))
+
+		require.Equal(head.Hash(), be.Blockchain().CurrentBlock().Hash())
+		require.Equal(ethertest.EthToWei(1), receiver.Balance(be))
+
+		nonce, err := be.PendingNonceAt(context.Background(), owner.Address())
+		require.Nil(err)
+		require.Equal(uint64(1), nonce)
+
+		require.NotNil(be.RevertTo(later))
+	}
+}
+
+func TestFork(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	c, _, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+	be.Commit()
+
+	fork, err := be.Fork()
+	require.Nil(err)
+	defer fork.Close()
+
+	require.Equal(be.Blockchain().CurrentBlock().Hash(), fork.Blockchain().CurrentBlock().Hash())
+
+	forked, err := tr.ContractAt(fork, "test.sol:Test", c.Address())
+	require.Nil(err)
+
+	_, err = forked.Transact(owner.TransactOpts(fork), "setValue", "forked value")
+	require.Nil(err)
+	fork.Commit()
+	require.Nil(owner.Transfer(fork, receiver.Address(), ethertest.EthToWei(1)))
+
+	value, err := forked.Call("value")
+	require.Nil(err)
+	require.Equal([]interface{}{"forked value"}, value)
+	require.Equal(ethertest.EthToWei(1), receiver.Balance(fork))
+
+	value, err = c.Call("value")
+	require.Nil(err)
+	require.Equal([]interface{}{"initial value"}, value)
+	require.Equal(0, receiver.Balance(be).Sign())
+}
+
+func TestAutomine(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend(ethertest.WithAutomine(true))
+	defer be.Close()
+
+	c, tx, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+
+	successful, err := ethertest.IsSuccessful(be, tx)
+	require.Nil(err)
+	require.True(successful)
+
+	_, err = c.Transact(owner.TransactOpts(be), "setValue", "new value")
+	require.Nil(err)
+
+	require.Nil(owner.Transfer(be, receiver.Address(), ethertest.EthToWei(1)))
+	require.Equal(int64(3), be.Blockchain().CurrentHeader().Number.Int64())
+
+	value, err := c.Call("value")
+	require.Nil(err)
+	require.Equal([]interface{}{"new value"}, value)
+
+	// a stopped blockchain can't import blocks
+	be.Blockchain().Stop()
+	tx = types.NewTransaction(3, receiver.Address(), ethertest.EthToWei(1), 21000, ethertest.GweiToWei(2), nil)
+	tx, err = owner.SignTransaction(be, tx)
+	require.Nil(err)
+	err = be.SendTransaction(context.Background(), tx)
+	require.ErrorIs(err, ethertest.ErrNotMined)
+
+	_, isPending, err := be.TransactionByHash(context.Background(), tx.Hash())
+	require.Nil(err)
+	require.True(isPending)
+}
+
+func TestMiningInterval(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+
+	require := require.New(t)
+	be := tr.NewTestBackend(ethertest.WithMiningInterval(10 * time.Millisecond))
+	defer be.Close()
+
+	n, err := be.PendingNonceAt(context.Background(), owner.Address())
+	require.Nil(err)
+
+	tx := types.NewTransaction(n, receiver.Address(), ethertest.EthToWei(1), 21000, ethertest.GweiToWei(2), nil)
+	tx, err = owner.SignTransaction(be, tx)
+	require.Nil(err)
+	require.Nil(be.SendTransaction(context.Background(), tx))
+
+	require.Eventually(func() bool {
+		return receiver.Balance(be).Cmp(ethertest.EthToWei(1)) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMiningIntervalWithConcurrentCalls(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend(ethertest.WithMiningInterval(time.Millisecond))
+	defer be.Close()
+
+	c, _, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+	require.Eventually(func() bool {
+		code, err := be.CodeAt(context.Background(), c.Address(), nil)
+		return err == nil && len(code) > 0
+	}, time.Second, time.Millisecond)
+
+	// blocks with the transactions are traced while gas of the next one is estimated
+	for i := 0; i < 20; i++ {
+		_, err = c.Transact(owner.TransactOpts(be), "setValue", "new value")
+		require.Nil(err)
+
+		_, err = c.Call("value")
+		require.Nil(err)
+
+		require.NotEmpty(tr.LastExecuted())
+		require.Nil(be.MiningError())
+	}
+}
+
+func TestFilterPendingLogsWhileMining(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+
+	require := require.New(t)
+	be := tr.NewTestBackend(ethertest.WithMiningInterval(time.Millisecond))
+	defer be.Close()
+
+	pending := big.NewInt(rpc.PendingBlockNumber.Int64())
+	for i := 0; i < 20; i++ {
+		n, err := be.PendingNonceAt(context.Background(), owner.Address())
+		require.Nil(err)
+		tx := types.NewTransaction(n, receiver.Address(), ethertest.EthToWei(1), 21000, ethertest.GweiToWei(2), nil)
+		tx, err = owner.SignTransaction(be, tx)
+		require.Nil(err)
+		require.Nil(be.SendTransaction(context.Background(), tx))
+
+		_, err = be.FilterLogs(context.Background(), ethereum.FilterQuery{FromBlock: pending, ToBlock: pending})
+		require.Nil(err)
+	}
+}
+
+func TestHistoricalState(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	c, _, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+	be.Commit()
+	deployed := be.Blockchain().CurrentBlock().Number
+
+	_, err = c.Transact(owner.TransactOpts(be), "setValue", "new value")
+	require.Nil(err)
+	be.Commit()
+	require.Nil(owner.Transfer(be, receiver.Address(), ethertest.EthToWei(1)))
+
+	ctx := context.Background()
+
+	value, err := c.CallAt(deployed, "value")
+	require.Nil(err)
+	require.Equal([]interface{}{"initial value"}, value)
+
+	_, err = c.CallAt(big.NewInt(0), "value")
+	require.Equal(bind.ErrNoCode, err)
+
+	value, err = c.Call("value")
+	require.Nil(err)
+	require.Equal([]interface{}{"new value"}, value)
+
+	balance, err := be.BalanceAt(ctx, receiver.Address(), deployed)
+	require.Nil(err)
+	require.Equal(0, balance.Sign())
+
+	nonce, err := be.NonceAt(ctx, owner.Address(), deployed)
+	require.Nil(err)
+	require.Equal(uint64(1), nonce)
+
+	nonce, err = be.NonceAt(ctx, owner.Address(), nil)
+	require.Nil(err)
+	require.Equal(uint64(3), nonce)
+
+	code, err := be.CodeAt(ctx, c.Address(), big.NewInt(0))
+	require.Nil(err)
+	require.Empty(code)
+
+	code, err = be.CodeAt(ctx, c.Address(), deployed)
+	require.Nil(err)
+	require.NotEmpty(code)
+
+	_, err = be.StorageAt(ctx, c.Address(), common.Hash{}, big.NewInt(100))
+	require.NotNil(err)
+}
+
+func TestSendTransactionErrors(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+
+	require := require.New(t)
+	be := tr.NewTestBackend(ethertest.WithBlockGasLimit(100000))
+	defer be.Close()
+
+	send := func(nonce uint64, amount *big.Int, gasLimit uint64, data []byte) error {
+		tx := types.NewTransaction(nonce, receiver.Address(), amount, gasLimit, ethertest.GweiToWei(2), data)
+		tx, err := owner.SignTransaction(be, tx)
+		require.Nil(err)
+		return be.SendTransaction(context.Background(), tx)
+	}
+
+	require.Nil(send(0, ethertest.EthToWei(1), 21000, nil))
+	require.Nil(be.Commit())
+
+	require.True(errors.Is(send(0, ethertest.EthToWei(1), 21000, nil), ethertest.ErrNonceTooLow))
+	require.True(errors.Is(send(65, ethertest.EthToWei(1), 21000, nil), ethertest.ErrNonceTooHigh))
+	require.True(errors.Is(send(1, ethertest.EthToWei(100), 21000, nil), ethertest.ErrInsufficientFunds))
+	require.True(errors.Is(send(1, ethertest.EthToWei(1), 21000, []byte{1}), ethertest.ErrIntrinsicGas))
+	require.True(errors.Is(send(1, ethertest.EthToWei(1), 200000, nil), ethertest.ErrGasLimitExceeded))
+
+	tx := types.NewTransaction(1, receiver.Address(), ethertest.EthToWei(1), 21000, ethertest.GweiToWei(2), nil)
+	tx, err := types.SignTx(tx, types.NewEIP155Signer(big.NewInt(5)), owner.PrivKey())
+	require.Nil(err)
+	require.True(errors.Is(be.SendTransaction(context.Background(), tx), ethertest.ErrInvalidSender))
+
+	require.Nil(be.Commit())
+	require.Equal(ethertest.EthToWei(1), receiver.Balance(be))
+}
+
+func TestTransactionPool(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var other = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddGenesisAccountAllocation(other.Address(), ethertest.EthToWei(100))
+
+	require := require.New(t)
+	be := tr.NewTestBackend(ethertest.WithBlockGasLimit(50000))
+	defer be.Close()
+
+	send := func(from *ethertest.Account, nonce uint64, amount int, gasPrice int) (*types.Transaction, error) {
+		tx := types.NewTransaction(nonce, receiver.Address(), ethertest.EthToWei(amount), 21000, ethertest.GweiToWei(gasPrice), nil)
+		tx, err := from.SignTransaction(be, tx)
+		require.Nil(err)
+		return tx, be.SendTransaction(context.Background(), tx)
+	}
+
+	pendingNonce := func() uint64 {
+		n, err := be.PendingNonceAt(context.Background(), owner.Address())
+		require.Nil(err)
+		return n
+	}
+
+	// transactions with a future nonce are queued until the gap is filled
+	queued, err := send(owner, 1, 1, 2)
+	require.Nil(err)
+	require.Equal(uint64(0), pendingNonce())
+	require.Nil(be.Commit())
+	require.Equal(0, receiver.Balance(be).Sign())
+
+	_, isPending, err := be.TransactionByHash(context.Background(), queued.Hash())
+	require.Nil(err)
+	require.True(isPending)
+
+	_, err = send(owner, 0, 1, 2)
+	require.Nil(err)
+	require.Equal(uint64(2), pendingNonce())
+	require.Nil(be.Commit())
+	require.Equal(ethertest.EthToWei(2), receiver.Balance(be))
+
+	// replacement needs at least 10% higher gas price
+	_, err = send(owner, 2, 1, 20)
+	require.Nil(err)
+	_, err = send(owner, 2, 1, 20)
+	require.True(errors.Is(err, ethertest.ErrAlreadyKnown))
+	_, err = send(owner, 2, 2, 20)
+	require.True(errors.Is(err, ethertest.ErrReplaceUnderpriced))
+	_, err = send(owner, 2, 3, 22)
+	require.Nil(err)
+	require.Nil(be.Commit())
+	require.Equal(ethertest.EthToWei(5), receiver.Balance(be))
+
+	// transactions that don't fit into the block are mined in the next one
+	_, err = send(owner, 3, 1, 2)
+	require.Nil(err)
+	_, err = send(owner, 4, 1, 2)
+	require.Nil(err)
+	_, err = send(owner, 5, 1, 2)
+	require.Nil(err)
+	require.Nil(be.Commit())
+	require.Equal(ethertest.EthToWei(7), receiver.Balance(be))
+	require.Nil(be.Commit())
+	require.Equal(ethertest.EthToWei(8), receiver.Balance(be))
+
+	// transactions are ordered by gas price
+	_, err = send(owner, 6, 1, 2)
+	require.Nil(err)
+	expensive, err := send(other, 0, 1, 10)
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	head := be.Blockchain().CurrentBlock()
+	transactions := be.Blockchain().GetBlockByHash(head.Hash()).Transactions()
+	require.Len(transactions, 2)
+	require.Equal(expensive.Hash(), transactions[0].Hash())
+}
+
+func TestDynamicFeeTransactions(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+
+	require := require.New(t)
+	be := tr.NewTestBackend(ethertest.WithBlockGasLimit(50000))
+	defer be.Close()
+
+	ctx := context.Background()
+	baseFee := func() *big.Int {
+		head, err := be.HeaderByNumber(ctx, nil)
+		require.Nil(err)
+		require.NotNil(head.BaseFee)
+		return head.BaseFee
+	}
+
+	// base fee decreases after a block below the gas target
+	initial := baseFee()
+	require.Nil(be.Commit())
+	require.Equal(-1, baseFee().Cmp(initial))
+
+	tip, err := be.SuggestGasTipCap(ctx)
+	require.Nil(err)
+	gasPrice, err := be.SuggestGasPrice(ctx)
+	require.Nil(err)
+	require.Equal(1, gasPrice.Cmp(tip))
+
+	send := func(tx types.TxData) (*types.Transaction, error) {
+		signed, err := owner.SignTransaction(be, types.NewTx(tx))
+		require.Nil(err)
+		return signed, be.SendTransaction(ctx, signed)
+	}
+
+	// base fee increases after a block above the gas target
+	n, err := be.PendingNonceAt(ctx, owner.Address())
+	require.Nil(err)
+	for i := uint64(0); i < 2; i++ {
+		_, err = send(&types.DynamicFeeTx{
+			Nonce:     n + i,
+			GasTipCap: ethertest.GweiToWei(1),
+			GasFeeCap: ethertest.GweiToWei(10),
+			Gas:       21000,
+			To:        &common.Address{},
+			Value:     big.NewInt(1),
+		})
+		require.Nil(err)
+	}
+	require.Nil(be.Commit())
+	before := baseFee()
+	require.Nil(be.Commit())
+	require.Equal(1, baseFee().Cmp(before))
+
+	// dynamic fee transactions pay the base fee and the tip
+	balance := owner.Balance(be)
+	tx, err := send(&types.DynamicFeeTx{
+		Nonce:     n + 2,
+		GasTipCap: ethertest.GweiToWei(1),
+		GasFeeCap: ethertest.GweiToWei(10),
+		Gas:       21000,
+		To:        &common.Address{},
+	})
+	require.Nil(err)
+	require.Nil(be.Commit())
+	receipt, err := be.TransactionReceipt(ctx, tx.Hash())
+	require.Nil(err)
+	require.Equal(uint8(types.DynamicFeeTxType), receipt.Type)
+	require.Equal(0, receipt.EffectiveGasPrice.Cmp(new(big.Int).Add(baseFee(), ethertest.GweiToWei(1))))
+	paid := new(big.Int).Mul(receipt.EffectiveGasPrice, big.NewInt(21000))
+	require.Equal(0, owner.Balance(be).Cmp(new(big.Int).Sub(balance, paid)))
+
+	// access list transactions
+	tx, err = send(&types.AccessListTx{
+		Nonce:      n + 3,
+		GasPrice:   ethertest.GweiToWei(2),
+		Gas:        30000,
+		To:         &common.Address{},
+		AccessList: types.AccessList{{Address: receiver.Address()}},
+	})
+	require.Nil(err)
+	require.Nil(be.Commit())
+	successful, err := ethertest.IsSuccessful(be, tx)
+	require.Nil(err)
+	require.True(successful)
+
+	_, err = send(&types.DynamicFeeTx{Nonce: n + 4, GasTipCap: ethertest.GweiToWei(2), GasFeeCap: ethertest.GweiToWei(1), Gas: 21000, To: &common.Address{}})
+	require.True(errors.Is(err, ethertest.ErrTipAboveFeeCap))
+	_, err = send(&types.DynamicFeeTx{Nonce: n + 4, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(1), Gas: 21000, To: &common.Address{}})
+	require.True(errors.Is(err, ethertest.ErrFeeCapTooLow))
+
+	// transfers and bindings send dynamic fee transactions
+	require.Nil(owner.Transfer(be, receiver.Address(), ethertest.EthToWei(1)))
+
+	opts := owner.TransactOpts(be, ethertest.WithGasFeeCap(ethertest.GweiToWei(5)), ethertest.WithGasTipCap(ethertest.GweiToWei(3)), ethertest.WithGasLimit(40000))
+	tx, err = bind.NewBoundContract(receiver.Address(), abi.ABI{}, be, be, be).RawTransact(opts, nil)
+	require.Nil(err)
+	require.Equal(uint8(types.DynamicFeeTxType), tx.Type())
+	require.Equal(ethertest.GweiToWei(5), tx.GasFeeCap())
+	require.Equal(ethertest.GweiToWei(3), tx.GasTipCap())
+}
+
+func TestCheatcodes(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	c, _, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	// the new balance can be spent in the same block
+	rich := ethertest.NewAccount()
+	require.Nil(be.SetBalance(rich.Address(), ethertest.EthToWei(10)))
+	require.Equal(big.NewInt(0), rich.Balance(be))
+	require.Nil(rich.Transfer(be, receiver.Address(), ethertest.EthToWei(1)))
+	require.Equal(ethertest.EthToWei(1), receiver.Balance(be))
+	require.Equal(-1, rich.Balance(be).Cmp(ethertest.EthToWei(9)))
+	require.NotNil(be.SetBalance(rich.Address(), big.NewInt(-1)))
+
+	require.Nil(be.SetNonce(owner.Address(), 10))
+	require.Nil(be.Commit())
+	nonce, err := be.NonceAt(context.Background(), owner.Address(), nil)
+	require.Nil(err)
+	require.Equal(uint64(10), nonce)
+	_, tx, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+	require.Equal(uint64(10), tx.Nonce())
+	require.Nil(be.Commit())
+
+	// short strings are stored in one slot with the length*2 in the last byte
+	mockValue := common.Hash{'m', 'o', 'c', 'k', 31: 8}
+	code, err := be.CodeAt(context.Background(), c.Address(), nil)
+	require.Nil(err)
+	mock := common.HexToAddress("0x1234")
+	require.Nil(be.SetCode(mock, code))
+	require.Nil(be.SetStorageAt(mock, common.Hash{}, mockValue))
+	require.Nil(be.Commit())
+
+	m, err := tr.ContractAt(be, "test.sol:Test", mock)
+	require.Nil(err)
+	value, err := m.Call("value")
+	require.Nil(err)
+	require.Equal([]interface{}{"mock"}, value)
+
+	// transactions in the block see the overridden storage
+	require.Nil(be.SetStorageAt(c.Address(), common.Hash{}, mockValue))
+	_, err = c.Transact(owner.TransactOpts(be), "setValue", "new value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+	value, err = c.Call("value")
+	require.Nil(err)
+	require.Equal([]interface{}{"new value"}, value)
+
+	fork, err := be.Fork()
+	require.Nil(err)
+	defer fork.Close()
+	require.Nil(owner.Transfer(fork, receiver.Address(), ethertest.EthToWei(1)))
+
+	require.Nil(be.SetCode(mock, nil))
+	be.Rollback()
+	require.Nil(be.Commit())
+	code, err = be.CodeAt(context.Background(), mock, nil)
+	require.Nil(err)
+	require.NotEmpty(code)
+}
+
+func TestGenesisAllocation(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var mock = common.HexToAddress("0x1234")
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+
+	c, _, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+	code, err := be.CodeAt(context.Background(), c.Address(), nil)
+	require.Nil(err)
+	be.Close()
+
+	tr.AddGenesisAllocation(mock, core.GenesisAccount{
+		Code:    code,
+		Storage: map[common.Hash]common.Hash{{}: {'m', 'o', 'c', 'k', 31: 8}},
+		Nonce:   1,
+	})
+	be = tr.NewTestBackend()
+	defer be.Close()
+
+	m, err := tr.ContractAt(be, "test.sol:Test", mock)
+	require.Nil(err)
+	value, err := m.Call("value")
+	require.Nil(err)
+	require.Equal([]interface{}{"mock"}, value)
+
+	nonce, err := be.NonceAt(context.Background(), mock, nil)
+	require.Nil(err)
+	require.Equal(uint64(1), nonce)
+}
+
+func TestBlockTime(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+	var receiver = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+
+	require := require.New(t)
+
+	// the blockchain time doesn't have to be before the wall clock time
+	genesis := time.Now().AddDate(10, 0, 0).Truncate(time.Second)
+	be := tr.NewTestBackend(ethertest.WithBlockchainTime(genesis))
+	defer be.Close()
+
+	headTime := func() time.Time {
+		return time.Unix(int64(be.Blockchain().CurrentHeader().Time), 0)
+	}
+	headNumber := func() uint64 {
+		return be.Blockchain().CurrentHeader().Number.Uint64()
+	}
+
+	require.Nil(owner.Transfer(be, receiver.Address(), ethertest.EthToWei(1)))
+	require.Equal(genesis.Add(10*time.Second), headTime())
+
+	require.Nil(be.SetBlockInterval(time.Hour))
+	require.Nil(be.Commit())
+	require.Equal(genesis.Add(time.Hour+10*time.Second), headTime())
+	require.NotNil(be.SetBlockInterval(500 * time.Millisecond))
+
+	next := headTime().Add(time.Minute)
+	require.Nil(be.SetNextBlockTimestamp(next))
+	require.Nil(owner.Transfer(be, receiver.Address(), ethertest.EthToWei(1)))
+	require.Equal(next, headTime())
+	require.NotNil(be.SetNextBlockTimestamp(next))
+
+	vesting := genesis.AddDate(0, 6, 0)
+	require.Nil(be.WarpTo(vesting))
+	require.Equal(vesting, headTime())
+	require.Equal(uint64(4), headNumber())
+
+	opts := owner.TransactOpts(be, ethertest.WithGasLimit(21000))
+	opts.Value = ethertest.EthToWei(1)
+	_, err := bind.NewBoundContract(receiver.Address(), abi.ABI{}, be, be, be).Transfer(opts)
+	require.Nil(err)
+	require.Nil(be.MineBlocks(100))
+	require.Equal(uint64(104), headNumber())
+	require.Equal(vesting.Add(100*time.Hour), headTime())
+	require.Equal(ethertest.EthToWei(3), receiver.Balance(be))
+}
+
+func TestTraceTransaction(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	c, _, err := tr.DeployContract(be, owner.TransactOpts(be), "test.sol:Test", "initial value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	_, err = c.Transact(owner.TransactOpts(be), "setValue", "first value")
+	require.Nil(err)
+	tx, err := c.Transact(owner.TransactOpts(be, ethertest.WithGasLimit(100000)), "setValue", "second value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+
+	receipt, err := be.TransactionReceipt(context.Background(), tx.Hash())
+	require.Nil(err)
+	require.Equal(uint(1), receipt.TransactionIndex)
+
+	trace := func(config *logger.Config) logger.ExecutionResult {
+		raw, err := be.TraceTransaction(context.Background(), tx.Hash(), config)
+		require.Nil(err)
+		var result logger.ExecutionResult
+		require.Nil(json.Unmarshal(raw, &result))
+		return result
+	}
+
+	result := trace(nil)
+	require.False(result.Failed)
+	require.Equal(receipt.GasUsed, result.Gas)
+	require.NotEmpty(result.StructLogs)
+	require.Equal(uint64(0), result.StructLogs[0].Pc)
+	require.Equal("PUSH1", result.StructLogs[0].Op)
+	require.Equal("STOP", result.StructLogs[len(result.StructLogs)-1].Op)
+
+	var withStack, withMemory, withStorage bool
+	for _, step := range result.StructLogs {
+		withStack = withStack || step.Stack != nil && len(*step.Stack) > 0
+		withMemory = withMemory || step.Memory != nil
+		withStorage = withStorage || step.Storage != nil && len(*step.Storage) > 0
+	}
+	require.True(withStack)
+	require.False(withMemory)
+	require.True(withStorage)
+
+	result = trace(&logger.Config{EnableMemory: true, DisableStack: true, DisableStorage: true})
+	withStack, withMemory, withStorage = false, false, false
+	for _, step := range result.StructLogs {
+		withStack = withStack || step.Stack != nil
+		withMemory = withMemory || step.Memory != nil && len(*step.Memory) > 0
+		withStorage = withStorage || step.Storage != nil
+	}
+	require.False(withStack)
+	require.True(withMemory)
+	require.False(withStorage)
+
+	_, err = be.TraceTransaction(context.Background(), common.Hash{}, nil)
+	require.NotNil(err)
+}
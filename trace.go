@@ -1,10 +1,25 @@
 package ethertest
 
 import (
+	"bufio"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
 )
 
+// TraceVersion is the version of the trace format written by SaveTrace.
+// Traces without a version, version 0, were saved before the format was versioned:
+// their steps have 3 fields, the contract index and the source range, or 5 fields,
+// followed by the call depth and the program counter, and their executions are
+// only the indexes of their first steps.
+const TraceVersion = 1
+
 type Contract struct {
 	Name   string `json:"name"`
 	Source string `json:"source"`
@@ -14,7 +29,7 @@ func (c Contract) lines(from, to int) (int, string) {
 
 	var realFrom, realTo int
 
-	for realFrom = from; realFrom > 0 && c.Source[realFrom] != '\n'; realFrom-- {
+	for realFrom = from; realFrom > 0 && c.Source[realFrom-1] != '\n'; realFrom-- {
 	}
 
 	for realTo = to; realTo < len(c.Source) && c.Source[realTo] != '\n'; realTo++ {
@@ -31,46 +46,244 @@ func (c Contract) lines(from, to int) (int, string) {
 
 }
 
+// Step is a statement of a registered contract that was executed.
+// It's encoded as a JSON array of numbers: [contract, from, to, depth, pc, gas, opcode].
 type Step struct {
 	contractIndex int
 	from          int
 	to            int
+	// depth is the call depth of the step, starting with 1 for the called contract of a transaction.
+	depth int
+	// pc is the program counter of the first instruction of the step.
+	pc uint64
+	// gas is the gas available before the first instruction of the step.
+	gas uint64
+	// op is the opcode of the first instruction of the step.
+	op vm.OpCode
 }
 
 func (s Step) MarshalJSON() ([]byte, error) {
-	return json.Marshal([]int{s.contractIndex, s.from, s.to})
+	return json.Marshal([]uint64{uint64(s.contractIndex), uint64(s.from), uint64(s.to), uint64(s.depth), s.pc, s.gas, uint64(s.op)})
+}
+
+// UnmarshalJSON reads steps with or without the call depth, the program counter and the gas and opcode.
+func (s *Step) UnmarshalJSON(data []byte) error {
+	values := []uint64{}
+	err := json.Unmarshal(data, &values)
+	if err != nil {
+		return err
+	}
+	if len(values) != 3 && len(values) != 5 && len(values) != 7 {
+		return fmt.Errorf("invalid trace step %s", string(data))
+	}
+	*s = Step{contractIndex: int(values[0]), from: int(values[1]), to: int(values[2])}
+	if len(values) >= 5 {
+		s.depth = int(values[3])
+		s.pc = values[4]
+	}
+	if len(values) == 7 {
+		s.gas = values[5]
+		s.op = vm.OpCode(values[6])
+	}
+	return nil
+}
+
+// Contract returns the index of the contract of the step in the contracts of the trace.
+func (s Step) Contract() int {
+	return s.contractIndex
+}
+
+// Range returns the source code range of the step.
+func (s Step) Range() (from, to int) {
+	return s.from, s.to
+}
+
+// Depth returns the call depth of the step, 0 if it's unknown.
+func (s Step) Depth() int {
+	return s.depth
 }
 
+// PC returns the program counter of the first instruction of the step.
+func (s Step) PC() uint64 {
+	return s.pc
+}
+
+// Gas returns the gas available before the first instruction of the step, 0 if it's unknown.
+func (s Step) Gas() uint64 {
+	return s.gas
+}
+
+// Op returns the opcode of the first instruction of the step, STOP if it's unknown.
+func (s Step) Op() vm.OpCode {
+	return s.op
+}
+
+// Execution describes a transaction, call or gas estimation that executed code of registered contracts.
+type Execution struct {
+	// Step is the index of the first step of the execution.
+	Step int `json:"step"`
+	// Block is the number of the block the execution was part of or based on.
+	Block uint64         `json:"block"`
+	From  common.Address `json:"from"`
+	// To is the called contract or the address of the deployed contract.
+	To     common.Address `json:"to"`
+	Create bool           `json:"create,omitempty"`
+	Value  *hexutil.Big   `json:"value"`
+	Input  hexutil.Bytes  `json:"input"`
+	// Gas is the gas limit and GasUsed includes the intrinsic gas.
+	Gas     uint64 `json:"gas"`
+	GasUsed uint64 `json:"gasUsed"`
+	// Error is the error of a failed execution, e.g. "execution reverted".
+	Error string `json:"error,omitempty"`
+}
+
+// UnmarshalJSON also reads executions saved as the index of their first step only.
+func (e *Execution) UnmarshalJSON(data []byte) error {
+	step := 0
+	if json.Unmarshal(data, &step) == nil {
+		*e = Execution{Step: step}
+		return nil
+	}
+	type execution Execution
+	return json.Unmarshal(data, (*execution)(e))
+}
+
+// Trace contains the executed statements of registered contracts, in the order of their execution.
+// The sources of the contracts are included, so a trace can be read without the contracts.
 type Trace struct {
+	Version   int        `json:"version"`
 	Contracts []Contract `json:"contracts"`
 	Steps     []Step     `json:"steps"`
+	// Executions contains every executed transaction, call and gas estimation in the order of their steps.
+	Executions []*Execution `json:"executions,omitempty"`
+}
+
+// LoadTrace reads a trace saved with SaveTrace, with or without gzip compression.
+func LoadTrace(r io.Reader) (*Trace, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	} else {
+		r = br
+	}
+
+	t := &Trace{}
+	err = json.NewDecoder(r).Decode(t)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trace: %w", err)
+	}
+	if t.Version > TraceVersion {
+		return nil, fmt.Errorf("unsupported trace version %d, the newest supported version is %d", t.Version, TraceVersion)
+	}
+	for i, s := range t.Steps {
+		if s.contractIndex >= len(t.Contracts) {
+			return nil, fmt.Errorf("step %d references contract %d, the trace has %d contracts", i, s.contractIndex, len(t.Contracts))
+		}
+		if s.from > s.to || s.to > len(t.Contracts[s.contractIndex].Source) {
+			return nil, fmt.Errorf("step %d has invalid source range %d:%d", i, s.from, s.to)
+		}
+	}
+	for i, e := range t.Executions {
+		if e == nil || e.Step < 0 || e.Step > len(t.Steps) || i > 0 && e.Step < t.Executions[i-1].Step {
+			return nil, fmt.Errorf("execution %d has an invalid first step", i)
+		}
+	}
+	return t, nil
+}
+
+// Save writes the trace as JSON, compressed with gzip if compress is set.
+func (t *Trace) Save(w io.Writer, compress bool) error {
+	if !compress {
+		return json.NewEncoder(w).Encode(t)
+	}
+	gw := gzip.NewWriter(w)
+	err := json.NewEncoder(gw).Encode(t)
+	if err != nil {
+		return err
+	}
+	return gw.Close()
 }
 
 func (t *Trace) LastStep() string {
 	if len(t.Steps) == 0 {
 		return "N/A"
 	}
-	step := t.Steps[len(t.Steps)-1]
+	name, line, source := t.Location(len(t.Steps) - 1)
+	return fmt.Sprintf("%s:%d\n%s\n", name, line, source)
+}
+
+// Location returns the contract name, the line number (starting with 1)
+// and the source lines of the step with the index.
+func (t *Trace) Location(i int) (string, int, string) {
+	step := t.Steps[i]
 	contract := t.Contracts[step.contractIndex]
 	lineNr, source := contract.lines(step.from, step.to)
-	return fmt.Sprintf("%s:%d\n%s\n", contract.Name, lineNr+1, source)
+	return contract.Name, lineNr + 1, source
 }
 
 type tracer struct {
 	trace *Trace
+	// execution is the current execution, it's added to the trace with its first step.
+	execution *Execution
+	// started is set when an execution started and none of its steps were recorded yet.
+	started bool
 }
 
 func newTracer() *tracer {
 	return &tracer{
-		trace: &Trace{},
+		trace: &Trace{Version: TraceVersion},
 	}
 }
 
 func (t *tracer) reset() {
-	t.trace = &Trace{}
+	t.trace = &Trace{Version: TraceVersion}
+}
+
+// executionStarted marks the start of a transaction, call or gas estimation with the gas limit.
+func (t *tracer) executionStarted(gasLimit uint64) {
+	t.started = true
+	t.execution = &Execution{Gas: gasLimit}
+}
+
+// executionEntered sets the message of the current execution.
+func (t *tracer) executionEntered(block uint64, from, to common.Address, create bool, input []byte, value *big.Int) {
+	if t.execution == nil {
+		return
+	}
+	t.execution.Block = block
+	t.execution.From = from
+	t.execution.To = to
+	t.execution.Create = create
+	t.execution.Input = common.CopyBytes(input)
+	if value != nil {
+		t.execution.Value = (*hexutil.Big)(new(big.Int).Set(value))
+	}
 }
 
-func (t *tracer) executed(name, source string, start, end int) {
+// executionFailed sets the error of the current execution.
+func (t *tracer) executionFailed(err error) {
+	if t.execution != nil && err != nil {
+		t.execution.Error = err.Error()
+	}
+}
+
+// executionEnded sets the gas used by the current execution with the gas that was left.
+// An execution without steps is not added to the trace.
+func (t *tracer) executionEnded(restGas uint64) {
+	if t.execution != nil {
+		t.execution.GasUsed = t.execution.Gas - restGas
+		t.execution = nil
+	}
+	t.started = false
+}
+
+func (t *tracer) executed(name, source string, start, end, depth int, pc uint64, op vm.OpCode, gas uint64) {
 	idx := -1
 	for i, c := range t.trace.Contracts {
 		if c.Name == name {
@@ -85,10 +298,19 @@ func (t *tracer) executed(name, source string, start, end int) {
 		})
 	}
 
-	newStep := Step{idx, start, end}
+	newStep := Step{idx, start, end, depth, pc, gas, op}
 
-	if len(t.trace.Steps) > 0 {
-		if t.trace.Steps[len(t.trace.Steps)-1] == newStep {
+	if t.started {
+		t.started = false
+		execution := t.execution
+		if execution == nil {
+			execution = &Execution{}
+		}
+		execution.Step = len(t.trace.Steps)
+		t.trace.Executions = append(t.trace.Executions, execution)
+	} else if len(t.trace.Steps) > 0 {
+		last := t.trace.Steps[len(t.trace.Steps)-1]
+		if last.contractIndex == idx && last.from == start && last.to == end && last.depth == depth {
 			return
 		}
 	}
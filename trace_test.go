@@ -0,0 +1,114 @@
+package ethertest_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tokencard/ethertest"
+)
+
+func TestSaveAndLoadTrace(t *testing.T) {
+	var tr = ethertest.NewTestRig()
+	var owner = ethertest.NewAccount()
+
+	tr.AddGenesisAccountAllocation(owner.Address(), ethertest.EthToWei(100))
+	tr.AddCoverageForContracts("./test/build/test/combined.json", "test/contracts")
+
+	require := require.New(t)
+	be := tr.NewTestBackend()
+	defer be.Close()
+
+	// without gas estimation, the trace only contains the deployment, the transaction and the call
+	opts := owner.TransactOpts(be, ethertest.WithGasLimit(1000000))
+	c, _, err := tr.DeployContract(be, opts, "test.sol:Test", "initial value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+	tx, err := c.Transact(opts, "setValue", "new value")
+	require.Nil(err)
+	require.Nil(be.Commit())
+	_, err = c.Call("willFail")
+	require.NotNil(err)
+
+	saved := &bytes.Buffer{}
+	require.Nil(tr.SaveTrace(saved))
+	require.Contains(saved.String(), `"version":1,`)
+
+	trace, err := ethertest.LoadTrace(saved)
+	require.Nil(err)
+	require.Equal(ethertest.TraceVersion, trace.Version)
+	require.Len(trace.Executions, 3)
+
+	deployment := trace.Executions[0]
+	require.Equal(0, deployment.Step)
+	require.True(deployment.Create)
+	require.Equal(owner.Address(), deployment.From)
+	require.Equal(c.Address(), deployment.To)
+	require.Equal(uint64(1000000), deployment.Gas)
+	require.Empty(deployment.Error)
+
+	receipt, err := be.TransactionReceipt(context.Background(), tx.Hash())
+	require.Nil(err)
+	transaction := trace.Executions[1]
+	require.False(transaction.Create)
+	require.Equal(c.Address(), transaction.To)
+	require.Equal(receipt.BlockNumber.Uint64(), transaction.Block)
+	require.Equal(tx.Data(), []byte(transaction.Input))
+	require.Equal(receipt.GasUsed, transaction.GasUsed)
+	require.Equal(int64(0), transaction.Value.ToInt().Int64())
+
+	call := trace.Executions[2]
+	require.Equal("execution reverted", call.Error)
+
+	step := trace.Steps[transaction.Step]
+	require.Equal(1, step.Depth())
+	require.NotZero(step.Gas())
+	require.Less(step.Gas(), transaction.Gas)
+	require.True(step.Op().IsPush(), step.Op().String())
+
+	compressed := &bytes.Buffer{}
+	require.Nil(tr.SaveTrace(compressed, ethertest.WithGzip()))
+	decompressed, err := ethertest.LoadTrace(compressed)
+	require.Nil(err)
+	require.Equal(trace, decompressed)
+}
+
+func TestLoadTrace(t *testing.T) {
+	require := require.New(t)
+
+	// traces saved before the format was versioned
+	trace, err := ethertest.LoadTrace(strings.NewReader(`{"contracts":[{"name":"a.sol","source":"contract A {\n  uint x;\n}"}],"steps":[[0,15,21]]}`))
+	require.Nil(err)
+	require.Equal(0, trace.Version)
+	require.Equal("a.sol:2\n  uint x;\n", trace.LastStep())
+	require.Equal(0, trace.Steps[0].Depth())
+
+	trace, err = ethertest.LoadTrace(strings.NewReader(`{"contracts":[{"name":"a.sol","source":"contract A {}"}],"steps":[[0,0,8,1,0]],"executions":[0]}`))
+	require.Nil(err)
+	require.Equal(0, trace.Executions[0].Step)
+	require.Equal(1, trace.Steps[0].Depth())
+
+	compressed := &bytes.Buffer{}
+	gw := gzip.NewWriter(compressed)
+	_, err = gw.Write([]byte(`{"version":1,"contracts":[],"steps":[]}`))
+	require.Nil(err)
+	require.Nil(gw.Close())
+	trace, err = ethertest.LoadTrace(compressed)
+	require.Nil(err)
+	require.Equal(1, trace.Version)
+
+	_, err = ethertest.LoadTrace(strings.NewReader(`{"version":2,"contracts":[],"steps":[]}`))
+	require.EqualError(err, "unsupported trace version 2, the newest supported version is 1")
+
+	_, err = ethertest.LoadTrace(strings.NewReader(`{"version":1,"contracts":[],"steps":[[0,0,1,1,0,100,96]]}`))
+	require.EqualError(err, "step 0 references contract 0, the trace has 0 contracts")
+
+	_, err = ethertest.LoadTrace(strings.NewReader(`{"version":1,"contracts":[{"name":"a.sol","source":"contract A {}"}],"steps":[[0,0,100,1,0,100,96]]}`))
+	require.EqualError(err, "step 0 has invalid source range 0:100")
+
+	_, err = ethertest.LoadTrace(strings.NewReader(`{"version":1,"contracts":[],"steps":[[0,0]]}`))
+	require.EqualError(err, "invalid trace: invalid trace step [0,0]")
+}
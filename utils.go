@@ -10,6 +10,15 @@ import (
 // OneEth is 10^18 Wei
 var OneEth = big.NewInt(1000000000000000000)
 
+// OneGwei is 10^9 Wei
+var OneGwei = big.NewInt(1000000000)
+
+// GweiToWei converts Gwei value to Wei
+func GweiToWei(amount int) *big.Int {
+	r := big.NewInt(1).Set(OneGwei)
+	return r.Mul(r, big.NewInt(int64(amount)))
+}
+
 // EthToWei converts ETH value to Wei
 func EthToWei(amount int) *big.Int {
 	r := big.NewInt(1).Set(OneEth)